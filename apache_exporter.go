@@ -8,9 +8,11 @@
 package main
 
 import (
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -28,6 +30,7 @@ import (
 )
 
 var (
+	serveCmd        = kingpin.Command("serve", "Serve metrics over HTTP. This is the default command.").Default()
 	metricsEndpoint = kingpin.Flag("telemetry.endpoint", "Path under which to expose metrics.").Default("/metrics").Envar("METRICS_ENDPOINT").String()
 	scrapeURI       = kingpin.Flag("scrape_uri", "URI to apache stub status page.").Default("http://localhost/server-status?auto").Envar("SCRAPE_URI").String()
 	hostOverride    = kingpin.Flag("host_override", "Override for HTTP Host header; empty string for no override.").Default("").Envar("HOST_OVERRIDE").String()
@@ -35,6 +38,40 @@ var (
 	toolkitFlags    = kingpinflag.AddFlags(kingpin.CommandLine, ":9117")
 	gracefulStop    = make(chan os.Signal, 1)
 	customHeaders   = kingpin.Flag("custom_headers", "Adds custom headers to the collector.").StringMap()
+	httpdConfig     = kingpin.Flag("httpd_config", "Path to the Apache httpd config file to read MPM limits and the config inventory from.").Default("").Envar("HTTPD_CONFIG").String()
+	maxReqWorkers   = kingpin.Flag("limits.max_request_workers", "MaxRequestWorkers, overriding the value from httpd_config.").Default("0").Int()
+	serverLimit     = kingpin.Flag("limits.server_limit", "ServerLimit, overriding the value from httpd_config.").Default("0").Int()
+	threadsPerChild = kingpin.Flag("limits.threads_per_child", "ThreadsPerChild, overriding the value from httpd_config.").Default("0").Int()
+	asyncFactor     = kingpin.Flag("limits.async_request_worker_factor", "AsyncRequestWorkerFactor, overriding the value from httpd_config.").Default("0").Float64()
+	configInventory = kingpin.Flag("collector.config_inventory", "Export the vhosts, listeners and modules found in httpd_config.").Default("false").Bool()
+	sslCertificates = kingpin.Flag("collector.ssl_certificates", "Export the expiry of certificates referenced by SSLCertificateFile and SSLCertificateChainFile in httpd_config.").Default("false").Bool()
+	sslCertGlob     = kingpin.Flag("ssl_cert_glob", "Glob of certificate files to export the expiry of, in addition to those found in httpd_config.").Default("").Envar("SSL_CERT_GLOB").String()
+	balancerManager = kingpin.Flag("balancer_manager_uri", "URI to the mod_proxy_balancer balancer-manager page; empty string to not scrape it.").Default("").Envar("BALANCER_MANAGER_URI").String()
+	jkStatus        = kingpin.Flag("jk_status_uri", "URI to the mod_jk status worker in properties format (?mime=prop); empty string to not scrape it.").Default("").Envar("JK_STATUS_URI").String()
+	mdStatus        = kingpin.Flag("md_status_uri", "URI to the mod_md md-status handler; empty string to not scrape it.").Default("").Envar("MD_STATUS_URI").String()
+	heartbeatAddr   = kingpin.Flag("heartbeat.listen_address", "Address to receive mod_heartbeat heartbeats on, e.g. the HeartbeatAddress 239.0.0.1:27999; empty string to not listen.").Default("").Envar("HEARTBEAT_LISTEN_ADDRESS").String()
+	heartbeatFile   = kingpin.Flag("heartbeat.storage_file", "Path to a mod_heartmonitor HeartbeatStorage file to read heartbeats from.").Default("").Envar("HEARTBEAT_STORAGE_FILE").String()
+	accessLogFiles  = kingpin.Flag("access_log.file", "Path to an access log to tail for request metrics. Repeatable.").Strings()
+	accessLogSyslog = kingpin.Flag("access_log.syslog_address", "Address to receive access log lines on with syslog, as udp://host:port or tcp://host:port; empty string to not listen.").Default("").Envar("ACCESS_LOG_SYSLOG_ADDRESS").String()
+	accessLogStdin  = kingpin.Flag("access_log.stdin", "Read access log lines from stdin, to be used as a piped logger with CustomLog \"|apache_exporter ...\".").Default("false").Bool()
+	accessLogFormat = kingpin.Flag("access_log.format", "LogFormat of the access logs, one of the nicknames common, combined, vhost_combined and combinedio, or json.").Default("common").Envar("ACCESS_LOG_FORMAT").String()
+	accessLogVHosts = kingpin.Flag("access_log.max_vhosts", "Maximum number of vhost label values of access log metrics; requests for further vhosts are counted as \"other\". 0 for no limit.").Default("100").Int()
+	errorLogFiles   = kingpin.Flag("error_log.file", "Path to an error log to tail for message counts. Repeatable.").Strings()
+	errorLogCodes   = kingpin.Flag("error_log.codes", "Comma separated AH message codes to count individually; others are counted as \"other\".").Default(strings.Join(collector.DefaultErrorLogCodes, ",")).Envar("ERROR_LOG_CODES").String()
+	modSecAuditLog  = kingpin.Flag("modsecurity.audit_log", "Path to the ModSecurity audit log (SecAuditLog) to tail, in the native or JSON format; empty string to not tail it.").Default("").Envar("MODSECURITY_AUDIT_LOG").String()
+	modSecStorage   = kingpin.Flag("modsecurity.storage_dir", "SecAuditLogStorageDir of a concurrent audit log, whose entry files are read as modsecurity.audit_log lists them.").Default("").Envar("MODSECURITY_STORAGE_DIR").String()
+	modSecMaxRules  = kingpin.Flag("modsecurity.max_rules", "Maximum number of rule_id label values of ModSecurity metrics; the hits of all but the most hit rules are counted as \"other\". 0 for no limit.").Default("100").Int()
+	modSecVHosts    = kingpin.Flag("modsecurity.max_vhosts", "Maximum number of vhost label values of ModSecurity metrics; further vhosts are counted as \"other\". 0 for no limit.").Default("100").Int()
+	statusPage      = kingpin.Flag("collector.status_page", "Scrape the HTML server-status page for HTTP/2 connections, the protocol of each connection (both requiring ExtendedStatus On) and mod_fcgid processes.").Default("false").Bool()
+	statusPageURI   = kingpin.Flag("status_page_uri", "URI to the HTML server-status page; defaults to scrape_uri without ?auto.").Default("").Envar("STATUS_PAGE_URI").String()
+	processes       = kingpin.Flag("collector.process", "Export the memory, file descriptors, threads and context switches of the httpd parent and children from procfs. Linux only.").Default("false").Bool()
+	processPidFile  = kingpin.Flag("process.pidfile", "Path to the httpd PidFile; defaults to finding the parent from the PIDs on the server-status page.").Default("").Envar("PROCESS_PIDFILE").String()
+	procfsPath      = kingpin.Flag("path.procfs", "procfs mountpoint.").Default("/proc").Envar("PROCFS_PATH").String()
+	listenQueue     = kingpin.Flag("collector.listen_queue", "Export the accept queue of the sockets Apache listens on and the kernel's listen overflow counters from procfs. Linux only.").Default("false").Bool()
+	listenPorts     = kingpin.Flag("listen_queue.port", "Port of a listen socket to export the accept queue of; defaults to the Listen directives of httpd_config, else the port of scrape_uri. Repeatable.").Ints()
+	relabelConfig   = kingpin.Flag("proxy_balancer.relabel_config", "Path to a YAML file with relabel_configs applied to proxy balancer and worker names.").Default("").Envar("PROXY_BALANCER_RELABEL_CONFIG").String()
+	maxBalancerWkrs = kingpin.Flag("proxy_balancer.max_workers", "Maximum number of proxy balancer workers exported per scrape; 0 for no limit.").Default("0").Int()
+	scoreboardFile  = kingpin.Flag("scoreboard_file", "Path to Apache's ScoreBoardFile. When set, the scoreboard is read from shared memory instead of scraping scrape_uri.").Default("").Envar("SCOREBOARD_FILE").String()
 )
 
 func main() {
@@ -44,28 +81,39 @@ func main() {
 	flag.AddFlags(kingpin.CommandLine, promslogConfig)
 	kingpin.HelpFlag.Short('h')
 	kingpin.Version(version.Print("apache_exporter"))
-	kingpin.Parse()
+	command := kingpin.Parse()
 	logger := promslog.New(promslogConfig)
+	config := newConfig(logger)
+
+	switch command {
+	case checkCmd.FullCommand():
+		os.Exit(checkCommand(os.Stdout, logger, config))
+	case collectCmd.FullCommand():
+		os.Exit(runCollect(os.Stdout, logger, config, *collectOutput))
+	case parseCmd.FullCommand():
+		os.Exit(runParse(os.Stdin, os.Stdout, os.Stderr, logger, config, *parseFile, *parseFormat))
+	case topCmd.FullCommand():
+		os.Exit(runTop(os.Stdout, logger, config, *topInterval, *topIterations, *topRequests))
+	case doctorCmd.FullCommand():
+		os.Exit(runDoctor(os.Stdout, logger, config))
+	}
+
 	// listen to termination signals from the OS
 	signal.Notify(gracefulStop, syscall.SIGTERM)
 	signal.Notify(gracefulStop, syscall.SIGINT)
 	signal.Notify(gracefulStop, syscall.SIGHUP)
 	signal.Notify(gracefulStop, syscall.SIGQUIT)
 
-	config := &collector.Config{
-		ScrapeURI:     *scrapeURI,
-		HostOverride:  *hostOverride,
-		Insecure:      *insecure,
-		CustomHeaders: *customHeaders,
-	}
-
-	exporter := collector.NewExporter(logger, config)
-	prometheus.MustRegister(exporter)
+	registerCollectors(prometheus.DefaultRegisterer, logger, config)
 	prometheus.MustRegister(versioncollector.NewCollector("apache_exporter"))
 
 	logger.Info("Starting apache_exporter", "version", version.Info())
 	logger.Info("Build context", "build", version.BuildContext())
-	logger.Info("Collect metrics from", "scrape_uri", *scrapeURI)
+	if config.ScoreboardFile != "" {
+		logger.Info("Collect metrics from", "scoreboard_file", *scoreboardFile)
+	} else {
+		logger.Info("Collect metrics from", "scrape_uri", *scrapeURI)
+	}
 
 	// listener for the termination signals from the OS
 	go func() {
@@ -102,3 +150,143 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// newConfig builds the collector config from flags, exiting on invalid
+// settings.
+func newConfig(logger *slog.Logger) *collector.Config {
+	config := &collector.Config{
+		ScrapeURI:              *scrapeURI,
+		HostOverride:           *hostOverride,
+		Insecure:               *insecure,
+		CustomHeaders:          *customHeaders,
+		ScoreboardFile:         *scoreboardFile,
+		HTTPDConfigFile:        *httpdConfig,
+		SSLCertificateGlob:     *sslCertGlob,
+		BalancerManagerURI:     *balancerManager,
+		JKStatusURI:            *jkStatus,
+		MDStatusURI:            *mdStatus,
+		StatusPageURI:          *statusPageURI,
+		ProcessPidFile:         *processPidFile,
+		ProcfsPath:             *procfsPath,
+		ListenQueuePorts:       *listenPorts,
+		HeartbeatAddress:       *heartbeatAddr,
+		HeartbeatStorageFile:   *heartbeatFile,
+		AccessLogFiles:         *accessLogFiles,
+		AccessLogSyslogAddress: *accessLogSyslog,
+		AccessLogFormat:        *accessLogFormat,
+		AccessLogMaxVHosts:     *accessLogVHosts,
+		ErrorLogFiles:          *errorLogFiles,
+		ErrorLogCodes:          strings.Split(*errorLogCodes, ","),
+		ModSecurityAuditLog:    *modSecAuditLog,
+		ModSecurityStorageDir:  *modSecStorage,
+		ModSecurityMaxRules:    *modSecMaxRules,
+		ModSecurityMaxVHosts:   *modSecVHosts,
+		Limits: collector.Limits{
+			MaxRequestWorkers:        float64(*maxReqWorkers),
+			ServerLimit:              float64(*serverLimit),
+			ThreadsPerChild:          float64(*threadsPerChild),
+			AsyncRequestWorkerFactor: *asyncFactor,
+		},
+	}
+
+	if *accessLogStdin {
+		config.AccessLogInput = os.Stdin
+	}
+
+	if *relabelConfig != "" || *maxBalancerWkrs > 0 {
+		var relabelConfigs []collector.RelabelConfig
+		if *relabelConfig != "" {
+			var err error
+			relabelConfigs, err = collector.LoadRelabelConfigs(*relabelConfig)
+			if err != nil {
+				logger.Error("Error loading proxy balancer relabel config", "err", err)
+				os.Exit(1)
+			}
+		}
+		config.BalancerRelabeler = collector.NewBalancerRelabeler(relabelConfigs, *maxBalancerWkrs)
+	}
+	return config
+}
+
+// registerCollectors registers the collectors enabled by flags, exiting on
+// invalid settings.
+func registerCollectors(reg prometheus.Registerer, logger *slog.Logger, config *collector.Config) {
+	if config.BalancerRelabeler != nil {
+		reg.MustRegister(config.BalancerRelabeler)
+	}
+
+	if config.ScoreboardFile != "" {
+		reg.MustRegister(collector.NewScoreboardFileCollector(logger, config))
+	} else {
+		reg.MustRegister(collector.NewExporter(logger, config))
+	}
+	if *configInventory {
+		if config.HTTPDConfigFile == "" {
+			logger.Error("--collector.config_inventory requires --httpd_config")
+			os.Exit(1)
+		}
+		reg.MustRegister(collector.NewConfigInventoryCollector(logger, config))
+	}
+	if *sslCertificates || config.SSLCertificateGlob != "" {
+		if config.HTTPDConfigFile == "" && config.SSLCertificateGlob == "" {
+			logger.Error("--collector.ssl_certificates requires --httpd_config or --ssl_cert_glob")
+			os.Exit(1)
+		}
+		reg.MustRegister(collector.NewSSLCertificateCollector(logger, config))
+	}
+	if config.BalancerManagerURI != "" {
+		reg.MustRegister(collector.NewBalancerManagerCollector(logger, config))
+	}
+	if config.JKStatusURI != "" {
+		reg.MustRegister(collector.NewJKStatusCollector(logger, config))
+	}
+	if config.MDStatusURI != "" {
+		reg.MustRegister(collector.NewMDCollector(logger, config))
+	}
+	if config.HeartbeatAddress != "" || config.HeartbeatStorageFile != "" {
+		c, err := collector.NewHeartbeatCollector(logger, config)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		reg.MustRegister(c)
+	}
+	if len(config.AccessLogFiles) > 0 || config.AccessLogSyslogAddress != "" || config.AccessLogInput != nil {
+		c, err := collector.NewAccessLogCollector(logger, config)
+		if err != nil {
+			logger.Error("Error setting up access log collector", "err", err)
+			os.Exit(1)
+		}
+		reg.MustRegister(c)
+	}
+	if len(config.ErrorLogFiles) > 0 {
+		reg.MustRegister(collector.NewErrorLogCollector(logger, config))
+	}
+	if config.ModSecurityAuditLog != "" {
+		reg.MustRegister(collector.NewModSecurityCollector(logger, config))
+	}
+	if *statusPage {
+		c, err := collector.NewStatusPageCollector(logger, config)
+		if err != nil {
+			logger.Error("Invalid server-status page URI", "err", err)
+			os.Exit(1)
+		}
+		reg.MustRegister(c)
+	}
+	if *processes {
+		c, err := collector.NewProcessCollector(logger, config)
+		if err != nil {
+			logger.Error("Error setting up process collector", "err", err)
+			os.Exit(1)
+		}
+		reg.MustRegister(c)
+	}
+	if *listenQueue {
+		c, err := collector.NewListenQueueCollector(logger, config)
+		if err != nil {
+			logger.Error("Error setting up listen queue collector", "err", err)
+			os.Exit(1)
+		}
+		reg.MustRegister(c)
+	}
+}
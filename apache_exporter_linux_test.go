@@ -0,0 +1,81 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Lusitaniae/apache_exporter/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/promslog"
+)
+
+// enableCollectorFlags turns on the collectors enabled by flags only for the
+// duration of the test.
+func enableCollectorFlags(t *testing.T) {
+	for _, flag := range []*bool{configInventory, sslCertificates, statusPage, processes, listenQueue} {
+		prev := *flag
+		*flag = true
+		t.Cleanup(func() { *flag = prev })
+	}
+}
+
+// TestRegisterAllCollectors registers every optional collector at once, which
+// panics if two of them describe metrics of the same name differently.
+func TestRegisterAllCollectors(t *testing.T) {
+	enableCollectorFlags(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(apache24EventStatus))
+	}))
+	defer server.Close()
+
+	// The Includes of the httpd config fixture are relative to the collector
+	// package.
+	t.Chdir("collector")
+	dir := t.TempDir()
+	var logs []string
+	for _, name := range []string{"access_log", "error_log", "modsec_audit.log"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		logs = append(logs, path)
+	}
+	config := &collector.Config{
+		ScrapeURI:            server.URL + "/server-status?auto",
+		HTTPDConfigFile:      "testdata/httpd/conf/httpd.conf",
+		BalancerManagerURI:   server.URL + "/balancer-manager",
+		JKStatusURI:          server.URL + "/jkstatus",
+		MDStatusURI:          server.URL + "/md-status",
+		ProcessPidFile:       "testdata/httpd.pid",
+		ProcfsPath:           "testdata/proc",
+		HeartbeatStorageFile: filepath.Join(dir, "hb.dat"),
+		AccessLogFiles:       logs[:1],
+		ErrorLogFiles:        logs[1:2],
+		ErrorLogCodes:        []string{"AH00161"},
+		ModSecurityAuditLog:  logs[2],
+		BalancerRelabeler:    collector.NewBalancerRelabeler(nil, 10),
+	}
+
+	for name, config := range map[string]*collector.Config{
+		"exporter": config,
+		"collect":  collectConfig(promslog.NewNopLogger(), config),
+	} {
+		t.Run(name, func(t *testing.T) {
+			reg := prometheus.NewPedanticRegistry()
+			registerCollectors(reg, promslog.NewNopLogger(), config)
+			if _, err := reg.Gather(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -8,12 +8,16 @@
 package main
 
 import (
+	"crypto/tls"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/Lusitaniae/apache_exporter/collector"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/promslog"
 )
 
@@ -259,10 +263,14 @@ Scoreboard: _W_______K..........................................................
 
 	metricCountApache22           = 19
 	metricCountApache24Event      = 34
-	metricCountApache24EventTLS   = 34
-	metricCountApache24EventProxy = 64
+	metricCountApache24EventTLS   = 49
+	metricCountApache24EventProxy = 136
 	metricCountApache24Worker     = 28
 	metricCountApache24Prefork    = 28
+
+	// The TLS handshake adds version, cipher suite, leaf and chain expiry
+	// and chain verification metrics.
+	metricCountTLSHandshake = 5
 )
 
 func checkApacheStatus(t *testing.T, status string, metricCount int) {
@@ -270,12 +278,17 @@ func checkApacheStatus(t *testing.T, status string, metricCount int) {
 		w.Write([]byte(status))
 	})
 	server := httptest.NewServer(handler)
+	defer server.Close()
+	checkApacheServer(t, server, false, metricCount)
+}
+
+func checkApacheServer(t *testing.T, server *httptest.Server, insecure bool, metricCount int) {
 	promslogConfig := &promslog.Config{}
 	logger := promslog.New(promslogConfig)
 	config := &collector.Config{
 		ScrapeURI:     server.URL,
 		HostOverride:  "",
-		Insecure:      false,
+		Insecure:      insecure,
 		CustomHeaders: map[string]string{"Cookie": "A test cookie"},
 	}
 	e := collector.NewExporter(logger, config)
@@ -301,26 +314,91 @@ func checkApacheStatus(t *testing.T, status string, metricCount int) {
 	}
 }
 
+// checkApacheGeneration checks the restart generations of a status page with
+// ParentServerConfigGeneration 1 and ParentServerMPMGeneration 0.
+func checkApacheGeneration(t *testing.T, status string) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(status))
+	}))
+	defer server.Close()
+
+	e := collector.NewExporter(promslog.NewNopLogger(), &collector.Config{ScrapeURI: server.URL})
+	expected := `
+# HELP apache_generation Apache restart generation
+# TYPE apache_generation gauge
+apache_generation{type="config"} 1
+apache_generation{type="mpm"} 0
+`
+	if err := testutil.CollectAndCompare(e, strings.NewReader(expected), "apache_generation"); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestApache22Status(t *testing.T) {
 	checkApacheStatus(t, apache22Status, metricCountApache22)
 }
 
 func TestApache24EventStatus(t *testing.T) {
 	checkApacheStatus(t, apache24EventStatus, metricCountApache24Event)
+	checkApacheGeneration(t, apache24EventStatus)
 }
 
 func TestApache24EventTLSStatus(t *testing.T) {
 	checkApacheStatus(t, apache24EventTLSStatus, metricCountApache24EventTLS)
+	checkApacheGeneration(t, apache24EventTLSStatus)
 }
 
 func TestApache24EventProxyStatus(t *testing.T) {
 	checkApacheStatus(t, apache24EventProxyStatus, metricCountApache24EventProxy)
+	checkApacheGeneration(t, apache24EventProxyStatus)
 }
 
 func TestApache24WorkerStatus(t *testing.T) {
 	checkApacheStatus(t, apache24WorkerStatus, metricCountApache24Worker)
+	checkApacheGeneration(t, apache24WorkerStatus)
 }
 
 func TestApache24PreforkStatus(t *testing.T) {
 	checkApacheStatus(t, apache24PreforkStatus, metricCountApache24Prefork)
+	checkApacheGeneration(t, apache24PreforkStatus)
+}
+
+func TestApache24EventStatusOverTLS(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(apache24EventStatus))
+	})
+	server := httptest.NewUnstartedServer(handler)
+	server.TLS = &tls.Config{
+		MaxVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+	}
+	server.StartTLS()
+	defer server.Close()
+	checkApacheServer(t, server, true, metricCountApache24Event+metricCountTLSHandshake)
+
+	e := collector.NewExporter(promslog.NewNopLogger(), &collector.Config{ScrapeURI: server.URL, Insecure: true})
+	// The test certificate is self-signed, so it does not verify against
+	// the system roots.
+	expected := `
+# HELP apache_target_tls_chain_verified Whether the certificate chain presented by the scrape target verifies against the system roots
+# TYPE apache_target_tls_chain_verified gauge
+apache_target_tls_chain_verified 0
+# HELP apache_target_tls_cipher_suite TLS cipher suite negotiated with the scrape target
+# TYPE apache_target_tls_cipher_suite gauge
+apache_target_tls_cipher_suite{cipher_suite="TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"} 1
+# HELP apache_target_tls_leaf_certificate_expiry_timestamp_seconds Expiry of the certificate presented by the scrape target, in seconds since the epoch
+# TYPE apache_target_tls_leaf_certificate_expiry_timestamp_seconds gauge
+apache_target_tls_leaf_certificate_expiry_timestamp_seconds{issuer="O=Acme Co",serial="%s",subject="O=Acme Co"} %d
+# HELP apache_target_tls_version TLS version negotiated with the scrape target
+# TYPE apache_target_tls_version gauge
+apache_target_tls_version{version="TLS 1.2"} 1
+`
+	cert := server.Certificate()
+	expected = fmt.Sprintf(expected, strings.ToUpper(cert.SerialNumber.Text(16)), cert.NotAfter.Unix())
+	if err := testutil.CollectAndCompare(e, strings.NewReader(expected),
+		"apache_target_tls_chain_verified", "apache_target_tls_cipher_suite",
+		"apache_target_tls_leaf_certificate_expiry_timestamp_seconds", "apache_target_tls_version"); err != nil {
+		t.Error(err)
+	}
 }
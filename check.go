@@ -0,0 +1,316 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/Lusitaniae/apache_exporter/collector"
+)
+
+var (
+	checkCmd              = kingpin.Command("check", "Scrape once and evaluate thresholds as a Nagios plugin, exiting 0 (OK), 1 (WARNING), 2 (CRITICAL) or 3 (UNKNOWN). Thresholds are Nagios ranges, e.g. 10 (alert outside 0..10), 10: (below 10) or @10:20 (inside 10..20); empty to not check.")
+	checkBusyWarning      = checkCmd.Flag("busy_ratio.warning", "Range of the busy worker ratio to warn outside of.").Default("0.8").String()
+	checkBusyCritical     = checkCmd.Flag("busy_ratio.critical", "Range of the busy worker ratio to be critical outside of.").Default("0.95").String()
+	checkIdleWarning      = checkCmd.Flag("idle_workers.warning", "Range of idle workers to warn outside of, e.g. 10: to warn below 10.").Default("").String()
+	checkIdleCritical     = checkCmd.Flag("idle_workers.critical", "Range of idle workers to be critical outside of.").Default("").String()
+	checkBalancerWarning  = checkCmd.Flag("balancer_errors.warning", "Range of proxy balancer members in error to warn outside of.").Default("0").String()
+	checkBalancerCritical = checkCmd.Flag("balancer_errors.critical", "Range of proxy balancer members in error to be critical outside of.").Default("").String()
+	checkUptimeWarning    = checkCmd.Flag("uptime.warning", "Range of the uptime in seconds to warn outside of, e.g. 300: to warn after a restart.").Default("").String()
+	checkUptimeCritical   = checkCmd.Flag("uptime.critical", "Range of the uptime in seconds to be critical outside of.").Default("").String()
+)
+
+// Nagios plugin states, which are also the exit codes.
+const (
+	checkOK = iota
+	checkWarning
+	checkCritical
+	checkUnknown
+)
+
+var checkStateNames = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+// checkSeverity orders states from best to worst, UNKNOWN ranking between OK
+// and WARNING as in the Nagios plugin guidelines.
+var checkSeverity = []int{checkOK: 0, checkUnknown: 1, checkWarning: 2, checkCritical: 3}
+
+// threshold is a Nagios plugin range: "10" alerts outside 0..10, "10:" below
+// 10, "~:10" above 10, "10:20" outside 10..20 and "@10:20" inside 10..20.
+type threshold struct {
+	spec       string
+	start, end float64
+	inside     bool
+}
+
+// parseThreshold parses a Nagios range, returning nil for an empty one.
+func parseThreshold(spec string) (*threshold, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	t := &threshold{spec: spec, end: math.Inf(1)}
+	s := spec
+	if strings.HasPrefix(s, "@") {
+		t.inside = true
+		s = s[1:]
+	}
+	start, end, ok := strings.Cut(s, ":")
+	if !ok {
+		start, end = "0", s
+	}
+	var err error
+	switch start {
+	case "~":
+		t.start = math.Inf(-1)
+	case "":
+	default:
+		if t.start, err = strconv.ParseFloat(start, 64); err != nil {
+			return nil, fmt.Errorf("invalid range %q", spec)
+		}
+	}
+	if end != "" {
+		if t.end, err = strconv.ParseFloat(end, 64); err != nil {
+			return nil, fmt.Errorf("invalid range %q", spec)
+		}
+	}
+	if t.start > t.end {
+		return nil, fmt.Errorf("invalid range %q: start is greater than end", spec)
+	}
+	return t, nil
+}
+
+// alert returns whether v is outside the range, or inside it for "@" ranges.
+func (t *threshold) alert(v float64) bool {
+	if t == nil {
+		return false
+	}
+	outside := v < t.start || v > t.end
+	return outside != t.inside
+}
+
+func (t *threshold) String() string {
+	if t == nil {
+		return ""
+	}
+	return t.spec
+}
+
+// checkThresholds are the warning and critical ranges of each checked value.
+type checkThresholds struct {
+	busyWarning, busyCritical         *threshold
+	idleWarning, idleCritical         *threshold
+	balancerWarning, balancerCritical *threshold
+	uptimeWarning, uptimeCritical     *threshold
+}
+
+// checkFlagThresholds parses the thresholds of the check command's flags.
+func checkFlagThresholds() (checkThresholds, error) {
+	var (
+		t   checkThresholds
+		err error
+	)
+	for _, f := range []struct {
+		dst  **threshold
+		spec string
+	}{
+		{&t.busyWarning, *checkBusyWarning},
+		{&t.busyCritical, *checkBusyCritical},
+		{&t.idleWarning, *checkIdleWarning},
+		{&t.idleCritical, *checkIdleCritical},
+		{&t.balancerWarning, *checkBalancerWarning},
+		{&t.balancerCritical, *checkBalancerCritical},
+		{&t.uptimeWarning, *checkUptimeWarning},
+		{&t.uptimeCritical, *checkUptimeCritical},
+	} {
+		if *f.dst, err = parseThreshold(f.spec); err != nil {
+			return t, err
+		}
+	}
+	return t, nil
+}
+
+// gatheredMetrics are gathered metric families by name.
+type gatheredMetrics map[string]*dto.MetricFamily
+
+func newGatheredMetrics(mfs []*dto.MetricFamily) gatheredMetrics {
+	g := make(gatheredMetrics)
+	for _, mf := range mfs {
+		g[mf.GetName()] = mf
+	}
+	return g
+}
+
+// value returns the sum of the metrics of a family with the given label
+// values, given as name/value pairs, and whether there were any.
+func (g gatheredMetrics) value(name string, labels ...string) (float64, bool) {
+	var (
+		sum   float64
+		found bool
+	)
+metrics:
+	for _, m := range g[name].GetMetric() {
+		for i := 0; i+1 < len(labels); i += 2 {
+			if metricLabel(m, labels[i]) != labels[i+1] {
+				continue metrics
+			}
+		}
+		found = true
+		sum += m.GetGauge().GetValue() + m.GetCounter().GetValue() + m.GetUntyped().GetValue()
+	}
+	return sum, found
+}
+
+func metricLabel(m *dto.Metric, name string) string {
+	for _, l := range m.GetLabel() {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
+
+// gatherScrape runs the collector of the scrape target once.
+func gatherScrape(logger *slog.Logger, config *collector.Config) (gatheredMetrics, error) {
+	reg := prometheus.NewRegistry()
+	if config.ScoreboardFile != "" {
+		reg.MustRegister(collector.NewScoreboardFileCollector(logger, config))
+	} else {
+		reg.MustRegister(collector.NewExporter(logger, config))
+	}
+	mfs, err := reg.Gather()
+	if err != nil {
+		return nil, err
+	}
+	return newGatheredMetrics(mfs), nil
+}
+
+// checkValue is a checked value, reported in the status line and perfdata.
+type checkValue struct {
+	label             string
+	value             float64
+	uom               string
+	warning, critical *threshold
+	min, max          string
+	state             int
+}
+
+// checkCommand runs the check command with the thresholds of its flags.
+func checkCommand(w io.Writer, logger *slog.Logger, config *collector.Config) int {
+	thresholds, err := checkFlagThresholds()
+	if err != nil {
+		fmt.Fprintf(w, "APACHE UNKNOWN - %s\n", err)
+		return checkUnknown
+	}
+	return runCheck(w, logger, config, thresholds)
+}
+
+// runCheck scrapes the target once, prints the Nagios plugin status line with
+// perfdata to w and returns the exit code.
+func runCheck(w io.Writer, logger *slog.Logger, config *collector.Config, thresholds checkThresholds) int {
+	g, err := gatherScrape(logger, config)
+	if err != nil {
+		fmt.Fprintf(w, "APACHE UNKNOWN - %s\n", err)
+		return checkUnknown
+	}
+	// Apache may be reachable but not answer with the status page, which
+	// only counts as a scrape failure.
+	up, _ := g.value("apache_up")
+	failures, _ := g.value("apache_exporter_scrape_failures_total")
+	if up == 0 || failures > 0 {
+		fmt.Fprintf(w, "APACHE CRITICAL - could not scrape %s\n", config.ScrapeURI)
+		return checkCritical
+	}
+
+	var values []*checkValue
+	add := func(v *checkValue, found bool, missing string) {
+		if !found {
+			// Only report missing values that have thresholds.
+			if v.warning != nil || v.critical != nil {
+				v.state = checkUnknown
+				v.label = v.label + " " + missing
+				values = append(values, v)
+			}
+			return
+		}
+		switch {
+		case v.critical.alert(v.value):
+			v.state = checkCritical
+		case v.warning.alert(v.value):
+			v.state = checkWarning
+		}
+		values = append(values, v)
+	}
+
+	busy := &checkValue{label: "busy_ratio", warning: thresholds.busyWarning, critical: thresholds.busyCritical, min: "0", max: "1"}
+	if ratio, ok := g.value("apache_worker_utilization_ratio"); ok {
+		busy.value = ratio
+		add(busy, true, "")
+	} else {
+		busyWorkers, okBusy := g.value("apache_workers", "state", "busy")
+		idleWorkers, okIdle := g.value("apache_workers", "state", "idle")
+		found := okBusy && okIdle && busyWorkers+idleWorkers > 0
+		if found {
+			busy.value = busyWorkers / (busyWorkers + idleWorkers)
+		}
+		add(busy, found, "not available")
+	}
+
+	idle := &checkValue{label: "idle_workers", warning: thresholds.idleWarning, critical: thresholds.idleCritical, min: "0"}
+	var ok bool
+	idle.value, ok = g.value("apache_workers", "state", "idle")
+	add(idle, ok, "not available")
+
+	// Without balancers there are no members in error.
+	balancer := &checkValue{label: "balancer_errors", warning: thresholds.balancerWarning, critical: thresholds.balancerCritical, min: "0"}
+	balancer.value, _ = g.value("apache_proxy_balancer_members", "state", "error")
+	add(balancer, true, "")
+
+	uptime := &checkValue{label: "uptime", uom: "s", warning: thresholds.uptimeWarning, critical: thresholds.uptimeCritical, min: "0"}
+	uptime.value, ok = g.value("apache_uptime_seconds_total")
+	add(uptime, ok, "not available, is ExtendedStatus On?")
+
+	state := checkOK
+	for _, v := range values {
+		if checkSeverity[v.state] > checkSeverity[state] {
+			state = v.state
+		}
+	}
+
+	// List the values in alert first, or all values when OK.
+	var summary, perfdata []string
+	for _, v := range values {
+		if v.state == checkUnknown {
+			summary = append(summary, v.label)
+			continue
+		}
+		text := fmt.Sprintf("%s %s%s", v.label, formatCheckValue(v.value), v.uom)
+		if v.state != checkOK {
+			text += fmt.Sprintf(" (%s)", strings.ToLower(checkStateNames[v.state]))
+		}
+		if state == checkOK || v.state != checkOK {
+			summary = append(summary, text)
+		}
+		perfdata = append(perfdata, strings.TrimRight(fmt.Sprintf("%s=%s%s;%s;%s;%s;%s",
+			v.label, formatCheckValue(v.value), v.uom, v.warning, v.critical, v.min, v.max), ";"))
+	}
+	fmt.Fprintf(w, "APACHE %s - %s | %s\n", checkStateNames[state], strings.Join(summary, ", "), strings.Join(perfdata, " "))
+	return state
+}
+
+// formatCheckValue formats a value with at most four decimals.
+func formatCheckValue(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1e4)/1e4, 'f', -1, 64)
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Lusitaniae/apache_exporter/collector"
+	"github.com/prometheus/common/promslog"
+)
+
+func mustParseThreshold(t *testing.T, spec string) *threshold {
+	t.Helper()
+	th, err := parseThreshold(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return th
+}
+
+func TestThreshold(t *testing.T) {
+	for _, test := range []struct {
+		spec   string
+		alerts []float64
+		passes []float64
+	}{
+		{"10", []float64{-1, 11}, []float64{0, 10}},
+		{"10:", []float64{9.5}, []float64{10, 1e9}},
+		{"~:10", []float64{10.5}, []float64{-1e9, 10}},
+		{"10:20", []float64{9, 21}, []float64{10, 20}},
+		{"@10:20", []float64{10, 15, 20}, []float64{9, 21}},
+	} {
+		th := mustParseThreshold(t, test.spec)
+		for _, v := range test.alerts {
+			if !th.alert(v) {
+				t.Errorf("range %q: expected %v to alert", test.spec, v)
+			}
+		}
+		for _, v := range test.passes {
+			if th.alert(v) {
+				t.Errorf("range %q: expected %v not to alert", test.spec, v)
+			}
+		}
+	}
+
+	for _, spec := range []string{"abc", "20:10", "1:x"} {
+		if _, err := parseThreshold(spec); err == nil {
+			t.Errorf("expected range %q to be invalid", spec)
+		}
+	}
+}
+
+func TestRunCheck(t *testing.T) {
+	balancerError := strings.Replace(apache24EventProxyStatus, "Status: Init Ok", "Status: Init Err", 1)
+
+	for _, test := range []struct {
+		name       string
+		status     string
+		thresholds func(t *testing.T) checkThresholds
+		code       int
+		output     string
+	}{
+		{
+			name:   "ok",
+			status: apache24EventStatus,
+			code:   checkOK,
+			output: "APACHE OK - busy_ratio 0.0133, idle_workers 74, balancer_errors 0, uptime 445s | busy_ratio=0.0133;0.8;0.95;0;1 idle_workers=74;;;0 balancer_errors=0;0;;0 uptime=445s;;;0\n",
+		},
+		{
+			name:   "idle warning",
+			status: apache24EventStatus,
+			thresholds: func(t *testing.T) checkThresholds {
+				return checkThresholds{idleWarning: mustParseThreshold(t, "100:"), idleCritical: mustParseThreshold(t, "10:")}
+			},
+			code:   checkWarning,
+			output: "APACHE WARNING - idle_workers 74 (warning) | busy_ratio=0.0133;;;0;1 idle_workers=74;100:;10:;0 balancer_errors=0;;;0 uptime=445s;;;0\n",
+		},
+		{
+			name:   "uptime critical",
+			status: apache24EventStatus,
+			thresholds: func(t *testing.T) checkThresholds {
+				return checkThresholds{idleWarning: mustParseThreshold(t, "100:"), uptimeCritical: mustParseThreshold(t, "600:")}
+			},
+			code:   checkCritical,
+			output: "APACHE CRITICAL - idle_workers 74 (warning), uptime 445s (critical) | busy_ratio=0.0133;;;0;1 idle_workers=74;100:;;0 balancer_errors=0;;;0 uptime=445s;;600:;0\n",
+		},
+		{
+			name:   "balancer member in error",
+			status: balancerError,
+			thresholds: func(t *testing.T) checkThresholds {
+				return checkThresholds{balancerWarning: mustParseThreshold(t, "0")}
+			},
+			code:   checkWarning,
+			output: "APACHE WARNING - balancer_errors 1 (warning) | busy_ratio=0.0133;;;0;1 idle_workers=74;;;0 balancer_errors=1;0;;0 uptime=445s;;;0\n",
+		},
+		{
+			name:   "no ExtendedStatus",
+			status: "BusyWorkers: 1\nIdleWorkers: 74\nScoreboard: _W___\n",
+			thresholds: func(t *testing.T) checkThresholds {
+				return checkThresholds{uptimeWarning: mustParseThreshold(t, "600:")}
+			},
+			code:   checkUnknown,
+			output: "APACHE UNKNOWN - uptime not available, is ExtendedStatus On? | busy_ratio=0.0133;;;0;1 idle_workers=74;;;0 balancer_errors=0;;;0\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(test.status))
+			}))
+			defer server.Close()
+
+			thresholds := checkThresholds{
+				busyWarning:     mustParseThreshold(t, "0.8"),
+				busyCritical:    mustParseThreshold(t, "0.95"),
+				balancerWarning: mustParseThreshold(t, "0"),
+			}
+			if test.thresholds != nil {
+				thresholds = test.thresholds(t)
+			}
+			var out strings.Builder
+			code := runCheck(&out, promslog.NewNopLogger(), &collector.Config{ScrapeURI: server.URL}, thresholds)
+			if code != test.code {
+				t.Errorf("expected exit code %d, got %d", test.code, code)
+			}
+			if out.String() != test.output {
+				t.Errorf("expected output\n%q\ngot\n%q", test.output, out.String())
+			}
+		})
+	}
+}
+
+func TestRunCheckDown(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	var out strings.Builder
+	code := runCheck(&out, promslog.NewNopLogger(), &collector.Config{ScrapeURI: server.URL}, checkThresholds{})
+	if code != checkCritical {
+		t.Errorf("expected exit code %d, got %d", checkCritical, code)
+	}
+	if !strings.HasPrefix(out.String(), "APACHE CRITICAL - could not scrape ") {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package main
+
+import (
+	"io"
+	"log/slog"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	versioncollector "github.com/prometheus/client_golang/prometheus/collectors/version"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/Lusitaniae/apache_exporter/collector"
+)
+
+var (
+	collectCmd    = kingpin.Command("collect", "Collect metrics once and write them for node_exporter's textfile collector, exiting 1 if Apache could not be scraped.")
+	collectOutput = collectCmd.Flag("output", "Path of the .prom file to write, replaced atomically; - for stdout.").Short('o').Default("-").String()
+)
+
+// collectConfig returns config without the sources that collectors stream
+// from: log files, syslog, stdin and the heartbeat listener. Collecting once
+// would start tailing and listening without ever seeing a message. The
+// heartbeat storage file is read on collection, so it is kept.
+func collectConfig(logger *slog.Logger, config *collector.Config) *collector.Config {
+	c := *config
+	if len(c.AccessLogFiles) > 0 || c.AccessLogSyslogAddress != "" || c.AccessLogInput != nil ||
+		len(c.ErrorLogFiles) > 0 || c.ModSecurityAuditLog != "" || c.HeartbeatAddress != "" {
+		logger.Warn("Log, syslog and heartbeat listener collectors are disabled in collect mode")
+	}
+	c.AccessLogFiles = nil
+	c.AccessLogSyslogAddress = ""
+	c.AccessLogInput = nil
+	c.ErrorLogFiles = nil
+	c.ModSecurityAuditLog = ""
+	c.HeartbeatAddress = ""
+	return &c
+}
+
+// runCollect gathers the collectors enabled by flags once and writes the
+// metrics to output, or stdout for "-". It returns the exit code.
+func runCollect(stdout io.Writer, logger *slog.Logger, config *collector.Config, output string) int {
+	// A registry of its own leaves out the Go runtime and process metrics of
+	// the exporter itself.
+	reg := prometheus.NewRegistry()
+	registerCollectors(reg, logger, collectConfig(logger, config))
+	reg.MustRegister(versioncollector.NewCollector("apache_exporter"))
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		logger.Error("Error gathering metrics", "err", err)
+		return 1
+	}
+
+	if output == "-" {
+		for _, mf := range mfs {
+			if _, err := expfmt.MetricFamilyToText(stdout, mf); err != nil {
+				logger.Error("Error writing metrics", "err", err)
+				return 1
+			}
+		}
+	} else {
+		// The metrics are written to <output>.<random> and renamed; the
+		// textfile collector only reads files ending in .prom, so it never
+		// reads the temporary file half-written.
+		gathered := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) { return mfs, nil })
+		if err := prometheus.WriteToTextfile(output, gathered); err != nil {
+			logger.Error("Error writing metrics", "file", output, "err", err)
+			return 1
+		}
+	}
+
+	g := newGatheredMetrics(mfs)
+	up, _ := g.value("apache_up")
+	failures, _ := g.value("apache_exporter_scrape_failures_total")
+	if up == 0 || failures > 0 {
+		logger.Error("Apache could not be scraped", "scrape_uri", config.ScrapeURI)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Lusitaniae/apache_exporter/collector"
+	"github.com/prometheus/common/promslog"
+)
+
+func TestRunCollect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(apache24EventStatus))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	output := filepath.Join(dir, "apache.prom")
+	if code := runCollect(nil, promslog.NewNopLogger(), &collector.Config{ScrapeURI: server.URL}, output); code != 0 {
+		t.Errorf("expected exit code 0, got %d", code)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"apache_up 1\n", "apache_accesses_total 131\n", "apache_exporter_build_info{"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %q in output", want)
+		}
+	}
+	if strings.Contains(string(data), "go_goroutines") {
+		t.Error("expected no Go runtime metrics in output")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("expected only the output file in %s, got %d files", dir, len(entries))
+	}
+}
+
+func TestRunCollectDown(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	var out strings.Builder
+	if code := runCollect(&out, promslog.NewNopLogger(), &collector.Config{ScrapeURI: server.URL}, "-"); code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(out.String(), "apache_up 0\n") {
+		t.Errorf("expected apache_up 0 in output, got %q", out.String())
+	}
+}
+
+func TestRunCollectSkipsStreamingCollectors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(apache24EventStatus))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	auditLog := filepath.Join(dir, "modsec_audit.log")
+	if err := os.WriteFile(auditLog, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config := &collector.Config{
+		ScrapeURI:           server.URL,
+		ModSecurityAuditLog: auditLog,
+		ErrorLogFiles:       []string{filepath.Join(dir, "error_log")},
+		HeartbeatAddress:    "127.0.0.1:0",
+	}
+
+	var out strings.Builder
+	if code := runCollect(&out, promslog.NewNopLogger(), config, "-"); code != 0 {
+		t.Errorf("expected exit code 0, got %d", code)
+	}
+	if strings.Contains(out.String(), "apache_modsecurity_") {
+		t.Error("expected no ModSecurity metrics in output")
+	}
+	if config.ModSecurityAuditLog != auditLog || config.HeartbeatAddress == "" {
+		t.Error("expected the config of the caller to be left alone")
+	}
+}
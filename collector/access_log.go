@@ -0,0 +1,234 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// accessLogMethods are the request methods exported as is, all others are
+// exported as "other".
+var accessLogMethods = map[string]bool{
+	"GET": true, "HEAD": true, "POST": true, "PUT": true, "DELETE": true,
+	"PATCH": true, "OPTIONS": true, "CONNECT": true, "TRACE": true,
+}
+
+// accessLogParser parses an access log line.
+type accessLogParser interface {
+	parse(line string) (logRecord, error)
+}
+
+// jsonLogFormat parses access logs written as one JSON object per line, e.g.
+// with
+//
+//	LogFormat "{\"vhost\":\"%v\",\"method\":\"%m\",\"status\":%>s,\"duration_us\":%D}" json
+//
+// The vhost, method (or request) and status keys are read, and the duration
+// from duration_us, duration_ms or duration (in seconds).
+type jsonLogFormat struct{}
+
+func (jsonLogFormat) parse(line string) (logRecord, error) {
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return logRecord{}, err
+	}
+	str := func(key string) string {
+		switch v := fields[key].(type) {
+		case string:
+			if v != "-" {
+				return v
+			}
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		return ""
+	}
+
+	r := logRecord{
+		vhost:  str("vhost"),
+		method: str("method"),
+		status: str("status"),
+	}
+	if r.method == "" {
+		r.method, _, _ = strings.Cut(str("request"), " ")
+	}
+	for _, d := range []struct {
+		key   string
+		scale float64
+	}{{"duration_us", 1e-6}, {"duration_ms", 1e-3}, {"duration", 1}} {
+		v := str(d.key)
+		if v == "" {
+			continue
+		}
+		val, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return logRecord{}, fmt.Errorf("invalid %s %q", d.key, v)
+		}
+		r.duration = val * d.scale
+		r.hasDuration = true
+		break
+	}
+	return r, nil
+}
+
+// newAccessLogParser returns the parser for a LogFormat string, LogFormat
+// nickname or "json".
+func newAccessLogParser(format string) (accessLogParser, error) {
+	if format == "json" {
+		return jsonLogFormat{}, nil
+	}
+	return parseLogFormat(format)
+}
+
+// AccessLogCollector exports request counts and latencies parsed from
+// Apache access logs, read from files, syslog or a pipe.
+type AccessLogCollector struct {
+	format accessLogParser
+	vhosts *labelLimiter
+	logger *slog.Logger
+
+	tailers []*tailer
+	syslog  *syslogReceiver
+
+	requests    *prometheus.CounterVec
+	duration    *prometheus.HistogramVec
+	parseErrors prometheus.Counter
+}
+
+// NewAccessLogCollector returns a collector parsing access log lines with
+// config.AccessLogFormat. Until Close is called, it tails
+// config.AccessLogFiles and receives lines on config.AccessLogSyslogAddress.
+// Lines are also read from config.AccessLogInput until it is exhausted.
+func NewAccessLogCollector(logger *slog.Logger, config *Config) (*AccessLogCollector, error) {
+	format, err := newAccessLogParser(config.AccessLogFormat)
+	if err != nil {
+		return nil, err
+	}
+	c := &AccessLogCollector{
+		format: format,
+		vhosts: newLabelLimiter(config.AccessLogMaxVHosts),
+		logger: logger,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests read from the access log",
+		},
+			[]string{"vhost", "method", "code"},
+		),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to serve HTTP requests, read from the access log",
+			Buckets:   prometheus.DefBuckets,
+		},
+			[]string{"vhost", "method"},
+		),
+		parseErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "access_log_parse_errors_total",
+			Help:      "Access log lines that could not be parsed",
+		}),
+	}
+
+	for _, path := range config.AccessLogFiles {
+		t := newTailer(logger, path, c.handleLine)
+		c.tailers = append(c.tailers, t)
+		go t.run()
+	}
+	if config.AccessLogSyslogAddress != "" {
+		if c.syslog, err = listenSyslog(logger, config.AccessLogSyslogAddress, c.handleLine); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("error listening for syslog messages: %w", err)
+		}
+	}
+	if config.AccessLogInput != nil {
+		go c.readInput(config.AccessLogInput)
+	}
+	return c, nil
+}
+
+// readInput handles the lines of r, e.g. stdin when the exporter is used as
+// a piped logger, until it is exhausted.
+func (c *AccessLogCollector) readInput(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		c.handleLine(scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		c.logger.Error("Error reading access log input", "err", err)
+		return
+	}
+	c.logger.Info("Access log input closed")
+}
+
+// Close stops tailing the access logs and receiving syslog messages.
+func (c *AccessLogCollector) Close() {
+	for _, t := range c.tailers {
+		t.close()
+	}
+	if c.syslog != nil {
+		c.syslog.close()
+	}
+}
+
+// handleLine parses an access log line and updates the metrics.
+func (c *AccessLogCollector) handleLine(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	r, err := c.format.parse(line)
+	if err != nil {
+		c.parseErrors.Inc()
+		c.logger.Debug("Error parsing access log line", "line", line, "err", err)
+		return
+	}
+	c.observe(r)
+}
+
+// observe updates the metrics for a request, bounding the label values: the
+// method to the standard ones, the code to a three digit number and the
+// vhost to the first maxVHosts seen.
+func (c *AccessLogCollector) observe(r logRecord) {
+	method := r.method
+	if !accessLogMethods[method] {
+		method = "other"
+	}
+	code := r.status
+	if n, err := strconv.Atoi(code); err != nil || n < 100 || n > 999 {
+		code = "invalid"
+	}
+
+	vhost := c.vhosts.value(r.vhost)
+	c.requests.WithLabelValues(vhost, method, code).Inc()
+	if r.hasDuration {
+		c.duration.WithLabelValues(vhost, method).Observe(r.duration)
+	}
+}
+
+// Describe implements Prometheus.Collector.
+func (c *AccessLogCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.requests.Describe(ch)
+	c.duration.Describe(ch)
+	c.parseErrors.Describe(ch)
+}
+
+// Collect implements Prometheus.Collector.
+func (c *AccessLogCollector) Collect(ch chan<- prometheus.Metric) {
+	c.requests.Collect(ch)
+	c.duration.Collect(ch)
+	c.parseErrors.Collect(ch)
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+)
+
+func TestLogFormat(t *testing.T) {
+	tests := []struct {
+		format, line string
+		expected     logRecord
+	}{
+		{
+			format:   "common",
+			line:     `192.0.2.1 - - [19/Oct/2026:10:15:02 +0000] "GET /index.html HTTP/1.1" 200 2326`,
+			expected: logRecord{method: "GET", status: "200"},
+		},
+		{
+			format:   `%h %l %u %t \"%r\" %>s %b %D %v`,
+			line:     `192.0.2.1 - frank [19/Oct/2026:10:15:02 +0000] "POST /api/orders HTTP/1.1" 201 - 48210 api.example.com`,
+			expected: logRecord{vhost: "api.example.com", method: "POST", status: "201", duration: 0.04821, hasDuration: true},
+		},
+		{
+			format:   "vhost_combined",
+			line:     `www.example.com:443 192.0.2.1 - - [19/Oct/2026:10:15:02 +0000] "GET /a\"b HTTP/1.1" 404 512 "-" "curl/8.5.0"`,
+			expected: logRecord{vhost: "www.example.com", method: "GET", status: "404"},
+		},
+		{
+			format:   `%V %m %s %>s %{ms}T "%{User-Agent}i"`,
+			line:     `shop.example.org DELETE 302 500 1250 "Mozilla/5.0 (X11; Linux x86_64)"`,
+			expected: logRecord{vhost: "shop.example.org", method: "DELETE", status: "500", duration: 1.25, hasDuration: true},
+		},
+	}
+	for _, test := range tests {
+		f, err := parseLogFormat(test.format)
+		if err != nil {
+			t.Fatalf("%s: %v", test.format, err)
+		}
+		got, err := f.parse(test.line)
+		if err != nil {
+			t.Errorf("%s: %v", test.format, err)
+			continue
+		}
+		if got != test.expected {
+			t.Errorf("%s: expected %+v, got %+v", test.format, test.expected, got)
+		}
+	}
+
+	if _, err := parseLogFormat("%{h}T"); err == nil {
+		t.Error("expected error for invalid time unit")
+	}
+}
+
+func TestAccessLogCollector(t *testing.T) {
+	c, err := NewAccessLogCollector(promslog.NewNopLogger(), &Config{
+		AccessLogFormat:    `%v %h %t "%r" %>s %b %D`,
+		AccessLogMaxVHosts: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`www.example.com 192.0.2.1 [19/Oct/2026:10:15:02 +0000] "GET / HTTP/1.1" 200 512 1200`,
+		`www.example.com 192.0.2.1 [19/Oct/2026:10:15:02 +0000] "GET /missing HTTP/1.1" 404 128 800`,
+		`api.example.com 192.0.2.2 [19/Oct/2026:10:15:03 +0000] "PROPFIND /dav HTTP/1.1" 207 77 2500000`,
+		`third.example.com 192.0.2.3 [19/Oct/2026:10:15:03 +0000] "GET / HTTP/1.1" 200 512 300`,
+		`garbage`,
+	} {
+		c.handleLine(line)
+	}
+
+	expected := `
+# HELP apache_access_log_parse_errors_total Access log lines that could not be parsed
+# TYPE apache_access_log_parse_errors_total counter
+apache_access_log_parse_errors_total 1
+# HELP apache_http_requests_total HTTP requests read from the access log
+# TYPE apache_http_requests_total counter
+apache_http_requests_total{code="200",method="GET",vhost="other"} 1
+apache_http_requests_total{code="200",method="GET",vhost="www.example.com"} 1
+apache_http_requests_total{code="207",method="other",vhost="api.example.com"} 1
+apache_http_requests_total{code="404",method="GET",vhost="www.example.com"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "apache_http_requests_total", "apache_access_log_parse_errors_total"); err != nil {
+		t.Error(err)
+	}
+	if n := testutil.CollectAndCount(c, "apache_http_request_duration_seconds"); n != 3 {
+		t.Errorf("expected 3 histograms, got %d", n)
+	}
+}
+
+func TestAccessLogInputJSON(t *testing.T) {
+	input := `{"vhost":"www.example.com","method":"GET","status":200,"duration_us":1500}
+{"vhost":"www.example.com","request":"POST /login HTTP/1.1","status":"302","duration_ms":20}
+not json
+`
+	c, err := NewAccessLogCollector(promslog.NewNopLogger(), &Config{
+		AccessLogFormat: "json",
+		AccessLogInput:  strings.NewReader(input),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	expected := `
+# HELP apache_access_log_parse_errors_total Access log lines that could not be parsed
+# TYPE apache_access_log_parse_errors_total counter
+apache_access_log_parse_errors_total 1
+# HELP apache_http_requests_total HTTP requests read from the access log
+# TYPE apache_http_requests_total counter
+apache_http_requests_total{code="200",method="GET",vhost="www.example.com"} 1
+apache_http_requests_total{code="302",method="POST",vhost="www.example.com"} 1
+`
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := testutil.CollectAndCompare(c, strings.NewReader(expected), "apache_http_requests_total", "apache_access_log_parse_errors_total")
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
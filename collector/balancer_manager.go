@@ -0,0 +1,355 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// Matches the balancer name in the "LoadBalancer Status for" heading.
+	reBalancerManagerName = regexp.MustCompile(`(balancer://[^\s\[]+)`)
+	// Matches health check counters shown as "configured (current)".
+	reBalancerManagerCount = regexp.MustCompile(`^(\d+)(?:\s*\((\d+)\))?$`)
+)
+
+// balancerManagerBalancer is a balancer as shown by the balancer-manager.
+type balancerManagerBalancer struct {
+	name             string
+	lbMethod         string
+	stickySession    string
+	timeout          float64
+	failoverAttempts float64
+	workers          []balancerManagerWorker
+}
+
+// balancerManagerWorker is a balancer member as shown by the
+// balancer-manager.
+type balancerManagerWorker struct {
+	name          string
+	route         string
+	routeRedirect string
+	status        string
+	lbFactor      float64
+	lbSet         float64
+	toBytes       float64
+	fromBytes     float64
+	hcMethod      string
+	hcInterval    float64
+	hcPasses      float64
+	hcFails       float64
+}
+
+// parseStrfsize parses a size formatted by apr_strfsize, e.g. "  0 ", "512 ",
+// "1.2K" or " 12M".
+func parseStrfsize(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "-" {
+		return 0, nil
+	}
+	multiplier := 1.0
+	if i := strings.IndexAny(s, "KMGTPE"); i == len(s)-1 {
+		multiplier = map[byte]float64{'K': 1 << 10, 'M': 1 << 20, 'G': 1 << 30, 'T': 1 << 40, 'P': 1 << 50, 'E': 1 << 60}[s[i]]
+		s = strings.TrimSpace(s[:i])
+	}
+	val, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	return val * multiplier, nil
+}
+
+// parseBalancerManagerCount returns the current count of a health check
+// counter shown as "configured (current)".
+func parseBalancerManagerCount(s string) (float64, error) {
+	m := reBalancerManagerCount.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("invalid health check count %q", s)
+	}
+	if m[2] != "" {
+		return strconv.ParseFloat(m[2], 64)
+	}
+	return strconv.ParseFloat(m[1], 64)
+}
+
+// parseBalancerManager reads the balancers and their workers from the
+// balancer-manager HTML page.
+func parseBalancerManager(data []byte) ([]balancerManagerBalancer, error) {
+	tables, err := parseHTMLTables(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var balancers []balancerManagerBalancer
+	for _, t := range tables {
+		switch {
+		case t.hasColumn("StickySession"):
+			m := reBalancerManagerName.FindStringSubmatch(t.before)
+			if m == nil || len(t.rows) == 0 {
+				return nil, fmt.Errorf("balancer table without balancer name: %q", t.before)
+			}
+			row := t.rows[0]
+			b := balancerManagerBalancer{
+				name:     m[1],
+				lbMethod: t.column(row, "Method"),
+			}
+			if sticky := t.column(row, "StickySession"); sticky != "(None)" {
+				b.stickySession = sticky
+			}
+			if b.timeout, err = strconv.ParseFloat(t.column(row, "Timeout"), 64); err != nil {
+				return nil, fmt.Errorf("%s: invalid Timeout: %w", b.name, err)
+			}
+			if b.failoverAttempts, err = strconv.ParseFloat(t.column(row, "FailoverAttempts"), 64); err != nil {
+				return nil, fmt.Errorf("%s: invalid FailoverAttempts: %w", b.name, err)
+			}
+			balancers = append(balancers, b)
+
+		case t.hasColumn("Worker URL"):
+			if len(balancers) == 0 {
+				return nil, fmt.Errorf("worker table before any balancer")
+			}
+			b := &balancers[len(balancers)-1]
+			for _, row := range t.rows {
+				w, err := parseBalancerManagerWorker(&t, row)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", b.name, err)
+				}
+				b.workers = append(b.workers, w)
+			}
+		}
+	}
+
+	return balancers, nil
+}
+
+func parseBalancerManagerWorker(t *htmlTable, row []string) (balancerManagerWorker, error) {
+	var err error
+	w := balancerManagerWorker{
+		name:          t.column(row, "Worker URL"),
+		route:         t.column(row, "Route"),
+		routeRedirect: t.column(row, "RouteRedir"),
+		status:        t.column(row, "Status"),
+		hcMethod:      t.column(row, "HC Method"),
+	}
+	if w.lbFactor, err = strconv.ParseFloat(t.column(row, "Factor"), 64); err != nil {
+		return w, fmt.Errorf("%s: invalid Factor: %w", w.name, err)
+	}
+	if w.lbSet, err = strconv.ParseFloat(t.column(row, "Set"), 64); err != nil {
+		return w, fmt.Errorf("%s: invalid Set: %w", w.name, err)
+	}
+	if w.toBytes, err = parseStrfsize(t.column(row, "To")); err != nil {
+		return w, fmt.Errorf("%s: invalid To: %w", w.name, err)
+	}
+	if w.fromBytes, err = parseStrfsize(t.column(row, "From")); err != nil {
+		return w, fmt.Errorf("%s: invalid From: %w", w.name, err)
+	}
+
+	// The health check columns are only present when mod_proxy_hcheck is
+	// loaded.
+	if !t.hasColumn("HC Method") {
+		return w, nil
+	}
+	if interval := strings.TrimSuffix(t.column(row, "HC Interval"), "ms"); interval != "" {
+		ms, err := strconv.ParseFloat(interval, 64)
+		if err != nil {
+			return w, fmt.Errorf("%s: invalid HC Interval: %w", w.name, err)
+		}
+		w.hcInterval = ms / 1000
+	}
+	if w.hcPasses, err = parseBalancerManagerCount(t.column(row, "Passes")); err != nil {
+		return w, fmt.Errorf("%s: %w", w.name, err)
+	}
+	if w.hcFails, err = parseBalancerManagerCount(t.column(row, "Fails")); err != nil {
+		return w, fmt.Errorf("%s: %w", w.name, err)
+	}
+	return w, nil
+}
+
+// BalancerManagerCollector scrapes the mod_proxy_balancer balancer-manager
+// page for the balancer and worker settings server-status does not show.
+type BalancerManagerCollector struct {
+	URI       string
+	mutex     sync.Mutex
+	http      *httpClient
+	relabeler *BalancerRelabeler
+	logger    *slog.Logger
+
+	up               *prometheus.Desc
+	balancerInfo     *prometheus.Desc
+	balancerTimeout  *prometheus.Desc
+	balancerFailover *prometheus.Desc
+	workerInfo       *prometheus.Desc
+	workerLBFactor   *prometheus.Desc
+	workerLBSet      *prometheus.Desc
+	workerToBytes    *prometheus.Desc
+	workerFromBytes  *prometheus.Desc
+	workerHCInterval *prometheus.Desc
+	workerHCPasses   *prometheus.Desc
+	workerHCFails    *prometheus.Desc
+	workerHCOk       *prometheus.Desc
+}
+
+func NewBalancerManagerCollector(logger *slog.Logger, config *Config) *BalancerManagerCollector {
+	workerLabels := []string{"balancer", "worker"}
+	return &BalancerManagerCollector{
+		URI:       config.BalancerManagerURI,
+		http:      newHTTPClient(config),
+		relabeler: config.BalancerRelabeler,
+		logger:    logger,
+		up: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "balancer_manager", "up"),
+			"Could the apache balancer-manager be reached",
+			nil,
+			nil),
+		balancerInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "proxy_balancer", "info"),
+			"Apache Proxy Balancer settings",
+			[]string{"balancer", "lb_method", "sticky_session"}, nil,
+		),
+		balancerTimeout: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "proxy_balancer", "timeout_seconds"),
+			"Apache Proxy Balancer timeout waiting for a free worker",
+			[]string{"balancer"}, nil,
+		),
+		balancerFailover: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "proxy_balancer", "failover_attempts"),
+			"Apache Proxy Balancer failover attempts before giving up",
+			[]string{"balancer"}, nil,
+		),
+		workerInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "proxy_balancer", "worker_info"),
+			"Apache Proxy Balancer Worker settings",
+			[]string{"balancer", "worker", "route", "route_redirect", "hc_method"}, nil,
+		),
+		workerLBFactor: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "proxy_balancer", "worker_lbfactor"),
+			"Apache Proxy Balancer Worker load factor",
+			workerLabels, nil,
+		),
+		workerLBSet: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "proxy_balancer", "worker_lbset"),
+			"Apache Proxy Balancer Worker load balancer set",
+			workerLabels, nil,
+		),
+		workerToBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "proxy_balancer", "worker_to_bytes_total"),
+			"Apache Proxy Balancer Worker bytes sent to the backend, as rounded by balancer-manager",
+			workerLabels, nil,
+		),
+		workerFromBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "proxy_balancer", "worker_from_bytes_total"),
+			"Apache Proxy Balancer Worker bytes received from the backend, as rounded by balancer-manager",
+			workerLabels, nil,
+		),
+		workerHCInterval: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "proxy_balancer", "worker_hc_interval_seconds"),
+			"Apache Proxy Balancer Worker health check interval",
+			workerLabels, nil,
+		),
+		workerHCPasses: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "proxy_balancer", "worker_hc_passes"),
+			"Apache Proxy Balancer Worker consecutive passed health checks",
+			workerLabels, nil,
+		),
+		workerHCFails: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "proxy_balancer", "worker_hc_fails"),
+			"Apache Proxy Balancer Worker consecutive failed health checks",
+			workerLabels, nil,
+		),
+		workerHCOk: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "proxy_balancer", "worker_hc_ok"),
+			"Whether the Apache Proxy Balancer Worker passes its health check",
+			workerLabels, nil,
+		),
+	}
+}
+
+// Describe implements Prometheus.Collector.
+func (c *BalancerManagerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up
+	ch <- c.balancerInfo
+	ch <- c.balancerTimeout
+	ch <- c.balancerFailover
+	ch <- c.workerInfo
+	ch <- c.workerLBFactor
+	ch <- c.workerLBSet
+	ch <- c.workerToBytes
+	ch <- c.workerFromBytes
+	ch <- c.workerHCInterval
+	ch <- c.workerHCPasses
+	ch <- c.workerHCFails
+	ch <- c.workerHCOk
+}
+
+func (c *BalancerManagerCollector) collect(ch chan<- prometheus.Metric) error {
+	data, err := c.http.get(c.URI)
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+		return err
+	}
+	balancers, err := parseBalancerManager(data)
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 1)
+
+	scrape := c.relabeler.newScrape()
+	seenBalancers := make(map[string]bool)
+	for _, b := range balancers {
+		name, keep := scrape.balancer(b.name)
+		if keep && !seenBalancers[name] {
+			seenBalancers[name] = true
+			ch <- prometheus.MustNewConstMetric(c.balancerInfo, prometheus.GaugeValue, 1, name, b.lbMethod, b.stickySession)
+			ch <- prometheus.MustNewConstMetric(c.balancerTimeout, prometheus.GaugeValue, b.timeout, name)
+			ch <- prometheus.MustNewConstMetric(c.balancerFailover, prometheus.GaugeValue, b.failoverAttempts, name)
+		}
+
+		for _, w := range b.workers {
+			balancer, worker, keep := scrape.worker(b.name, w.name)
+			if !keep {
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(c.workerInfo, prometheus.GaugeValue, 1, balancer, worker, w.route, w.routeRedirect, w.hcMethod)
+			ch <- prometheus.MustNewConstMetric(c.workerLBFactor, prometheus.GaugeValue, w.lbFactor, balancer, worker)
+			ch <- prometheus.MustNewConstMetric(c.workerLBSet, prometheus.GaugeValue, w.lbSet, balancer, worker)
+			ch <- prometheus.MustNewConstMetric(c.workerToBytes, prometheus.CounterValue, w.toBytes, balancer, worker)
+			ch <- prometheus.MustNewConstMetric(c.workerFromBytes, prometheus.CounterValue, w.fromBytes, balancer, worker)
+
+			if w.hcMethod == "" || strings.EqualFold(w.hcMethod, "NONE") {
+				continue
+			}
+			hcOk := 1.0
+			if strings.Contains(w.status, "HcFl") {
+				hcOk = 0
+			}
+			ch <- prometheus.MustNewConstMetric(c.workerHCInterval, prometheus.GaugeValue, w.hcInterval, balancer, worker)
+			ch <- prometheus.MustNewConstMetric(c.workerHCPasses, prometheus.GaugeValue, w.hcPasses, balancer, worker)
+			ch <- prometheus.MustNewConstMetric(c.workerHCFails, prometheus.GaugeValue, w.hcFails, balancer, worker)
+			ch <- prometheus.MustNewConstMetric(c.workerHCOk, prometheus.GaugeValue, hcOk, balancer, worker)
+		}
+	}
+
+	return nil
+}
+
+// Collect implements Prometheus.Collector.
+func (c *BalancerManagerCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.collect(ch); err != nil {
+		c.logger.Error("Error scraping Apache balancer-manager", "err", err)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+)
+
+func TestBalancerManager(t *testing.T) {
+	page, err := os.ReadFile("testdata/balancer-manager.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(page)
+	}))
+	defer server.Close()
+
+	c := NewBalancerManagerCollector(promslog.NewNopLogger(), &Config{BalancerManagerURI: server.URL})
+
+	expected := `
+# HELP apache_balancer_manager_up Could the apache balancer-manager be reached
+# TYPE apache_balancer_manager_up gauge
+apache_balancer_manager_up 1
+# HELP apache_proxy_balancer_failover_attempts Apache Proxy Balancer failover attempts before giving up
+# TYPE apache_proxy_balancer_failover_attempts gauge
+apache_proxy_balancer_failover_attempts{balancer="balancer://app"} 1
+apache_proxy_balancer_failover_attempts{balancer="balancer://static"} 0
+# HELP apache_proxy_balancer_info Apache Proxy Balancer settings
+# TYPE apache_proxy_balancer_info gauge
+apache_proxy_balancer_info{balancer="balancer://app",lb_method="byrequests",sticky_session="JSESSIONID | jsessionid"} 1
+apache_proxy_balancer_info{balancer="balancer://static",lb_method="bybusyness",sticky_session=""} 1
+# HELP apache_proxy_balancer_timeout_seconds Apache Proxy Balancer timeout waiting for a free worker
+# TYPE apache_proxy_balancer_timeout_seconds gauge
+apache_proxy_balancer_timeout_seconds{balancer="balancer://app"} 5
+apache_proxy_balancer_timeout_seconds{balancer="balancer://static"} 0
+# HELP apache_proxy_balancer_worker_from_bytes_total Apache Proxy Balancer Worker bytes received from the backend, as rounded by balancer-manager
+# TYPE apache_proxy_balancer_worker_from_bytes_total counter
+apache_proxy_balancer_worker_from_bytes_total{balancer="balancer://app",worker="http://app-01:8080"} 12288
+apache_proxy_balancer_worker_from_bytes_total{balancer="balancer://app",worker="http://app-02:8080"} 512
+apache_proxy_balancer_worker_from_bytes_total{balancer="balancer://static",worker="http://cdn-01:80"} 3072
+# HELP apache_proxy_balancer_worker_hc_fails Apache Proxy Balancer Worker consecutive failed health checks
+# TYPE apache_proxy_balancer_worker_hc_fails gauge
+apache_proxy_balancer_worker_hc_fails{balancer="balancer://app",worker="http://app-01:8080"} 0
+apache_proxy_balancer_worker_hc_fails{balancer="balancer://app",worker="http://app-02:8080"} 3
+# HELP apache_proxy_balancer_worker_hc_interval_seconds Apache Proxy Balancer Worker health check interval
+# TYPE apache_proxy_balancer_worker_hc_interval_seconds gauge
+apache_proxy_balancer_worker_hc_interval_seconds{balancer="balancer://app",worker="http://app-01:8080"} 30
+apache_proxy_balancer_worker_hc_interval_seconds{balancer="balancer://app",worker="http://app-02:8080"} 30
+# HELP apache_proxy_balancer_worker_hc_ok Whether the Apache Proxy Balancer Worker passes its health check
+# TYPE apache_proxy_balancer_worker_hc_ok gauge
+apache_proxy_balancer_worker_hc_ok{balancer="balancer://app",worker="http://app-01:8080"} 1
+apache_proxy_balancer_worker_hc_ok{balancer="balancer://app",worker="http://app-02:8080"} 0
+# HELP apache_proxy_balancer_worker_hc_passes Apache Proxy Balancer Worker consecutive passed health checks
+# TYPE apache_proxy_balancer_worker_hc_passes gauge
+apache_proxy_balancer_worker_hc_passes{balancer="balancer://app",worker="http://app-01:8080"} 1
+apache_proxy_balancer_worker_hc_passes{balancer="balancer://app",worker="http://app-02:8080"} 0
+# HELP apache_proxy_balancer_worker_info Apache Proxy Balancer Worker settings
+# TYPE apache_proxy_balancer_worker_info gauge
+apache_proxy_balancer_worker_info{balancer="balancer://app",hc_method="GET",route="node1",route_redirect="",worker="http://app-01:8080"} 1
+apache_proxy_balancer_worker_info{balancer="balancer://app",hc_method="GET",route="node2",route_redirect="node1",worker="http://app-02:8080"} 1
+apache_proxy_balancer_worker_info{balancer="balancer://static",hc_method="NONE",route="",route_redirect="",worker="http://cdn-01:80"} 1
+# HELP apache_proxy_balancer_worker_lbfactor Apache Proxy Balancer Worker load factor
+# TYPE apache_proxy_balancer_worker_lbfactor gauge
+apache_proxy_balancer_worker_lbfactor{balancer="balancer://app",worker="http://app-01:8080"} 1
+apache_proxy_balancer_worker_lbfactor{balancer="balancer://app",worker="http://app-02:8080"} 2
+apache_proxy_balancer_worker_lbfactor{balancer="balancer://static",worker="http://cdn-01:80"} 1
+# HELP apache_proxy_balancer_worker_lbset Apache Proxy Balancer Worker load balancer set
+# TYPE apache_proxy_balancer_worker_lbset gauge
+apache_proxy_balancer_worker_lbset{balancer="balancer://app",worker="http://app-01:8080"} 0
+apache_proxy_balancer_worker_lbset{balancer="balancer://app",worker="http://app-02:8080"} 1
+apache_proxy_balancer_worker_lbset{balancer="balancer://static",worker="http://cdn-01:80"} 0
+# HELP apache_proxy_balancer_worker_to_bytes_total Apache Proxy Balancer Worker bytes sent to the backend, as rounded by balancer-manager
+# TYPE apache_proxy_balancer_worker_to_bytes_total counter
+apache_proxy_balancer_worker_to_bytes_total{balancer="balancer://app",worker="http://app-01:8080"} 5.6623104e+06
+apache_proxy_balancer_worker_to_bytes_total{balancer="balancer://app",worker="http://app-02:8080"} 0
+apache_proxy_balancer_worker_to_bytes_total{balancer="balancer://static",worker="http://cdn-01:80"} 1.2884901888e+09
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseStrfsize(t *testing.T) {
+	tests := map[string]float64{
+		"  0 ": 0,
+		"512 ": 512,
+		"1.2K": 1.2 * 1024,
+		" 12M": 12 * 1024 * 1024,
+	}
+	for in, expected := range tests {
+		got, err := parseStrfsize(in)
+		if err != nil {
+			t.Errorf("%q: %v", in, err)
+		}
+		if got != expected {
+			t.Errorf("%q: expected %v, got %v", in, expected, got)
+		}
+	}
+}
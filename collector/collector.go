@@ -11,9 +11,11 @@ import (
 	"bufio"
 	"bytes"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"regexp"
 	"strconv"
@@ -21,7 +23,6 @@ import (
 	"sync"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/common/version"
 )
 
 const (
@@ -32,15 +33,23 @@ var (
 	// Regular expressions for matching proxy balancer status lines.
 	reProxyBalName   = regexp.MustCompile(`ProxyBalancer\[\d+\]Name`)
 	reProxyBalWorker = regexp.MustCompile(`ProxyBalancer\[\d+\]Worker\[\d+\](\S+)`)
+
+	// Flags mod_proxy reports in a balancer worker's Status.
+	proxyBalancerWorkerFlags = []string{"Init", "Ok", "Err", "Dis", "Stop", "Drn", "Stby", "Hot", "Ign", "HcFl", "Spar"}
 )
 
 type Exporter struct {
-	URI           string
-	hostOverride  string
-	customHeaders map[string]string
-	mutex         sync.Mutex
-	client        *http.Client
-	userAgent     string
+	URI       string
+	mutex     sync.Mutex
+	http      *httpClient
+	limits    Limits
+	relabeler *BalancerRelabeler
+	// configDirectives are the directives of HTTPDConfigFile, which is
+	// read once rather than on every scrape.
+	configDirectives []configDirective
+	// tlsRoots are the CAs the chain of the scrape target is verified
+	// against; nil for the system roots.
+	tlsRoots *x509.CertPool
 
 	up                    *prometheus.Desc
 	scrapeFailures        prometheus.Counter
@@ -59,26 +68,114 @@ type Exporter struct {
 	connections           *prometheus.GaugeVec
 	scoreboard            *prometheus.GaugeVec
 	proxyBalancerStatus   *prometheus.GaugeVec
+	proxyBalancerFlag     *prometheus.GaugeVec
+	proxyBalancerMembers  *prometheus.GaugeVec
 	proxyBalancerElected  *prometheus.Desc
 	proxyBalancerBusy     *prometheus.GaugeVec
 	proxyBalancerReqSize  *prometheus.Desc
 	proxyBalancerRespSize *prometheus.Desc
+	configLimit           *prometheus.Desc
+	workerUtilization     *prometheus.Desc
+	asyncCapacity         *prometheus.Desc
+	asyncUtilization      *prometheus.Desc
+	tlsVersion            *prometheus.Desc
+	tlsCipherSuite        *prometheus.Desc
+	tlsLeafExpiry         *prometheus.Desc
+	tlsChainExpiry        *prometheus.Desc
+	tlsChainVerified      *prometheus.Desc
+	tlsSessionCache       *tlsSessionCacheMetrics
 	logger                *slog.Logger
 }
 
 type Config struct {
-	ScrapeURI     string
-	HostOverride  string
-	Insecure      bool
-	CustomHeaders map[string]string
+	ScrapeURI      string
+	HostOverride   string
+	Insecure       bool
+	CustomHeaders  map[string]string
+	ScoreboardFile string
+	// HTTPDConfigFile is parsed for MPM limits when set.
+	HTTPDConfigFile string
+	// Limits override the values found in HTTPDConfigFile.
+	Limits Limits
+	// SSLCertificateGlob matches certificate files to read in addition to
+	// those referenced by HTTPDConfigFile.
+	SSLCertificateGlob string
+	// BalancerManagerURI is the mod_proxy_balancer balancer-manager page to
+	// scrape, if any.
+	BalancerManagerURI string
+	// JKStatusURI is the mod_jk status worker to scrape in properties
+	// format, if any.
+	JKStatusURI string
+	// MDStatusURI is the mod_md md-status handler to scrape, if any.
+	MDStatusURI string
+	// HeartbeatAddress is the mod_heartbeat address to receive heartbeats
+	// on, if any.
+	HeartbeatAddress string
+	// HeartbeatStorageFile is the mod_heartmonitor HeartbeatStorage file to
+	// read, if any.
+	HeartbeatStorageFile string
+	// AccessLogFiles are the access logs to tail.
+	AccessLogFiles []string
+	// AccessLogSyslogAddress is the address to receive access log lines
+	// on with syslog, if any.
+	AccessLogSyslogAddress string
+	// AccessLogInput is read for access log lines, e.g. os.Stdin when used
+	// as a piped logger. It may be nil.
+	AccessLogInput io.Reader
+	// AccessLogFormat is the LogFormat string or nickname of the access
+	// logs, or "json".
+	AccessLogFormat string
+	// AccessLogMaxVHosts bounds the vhost label of access log metrics, 0
+	// for no limit.
+	AccessLogMaxVHosts int
+	// ErrorLogFiles are the error logs to tail.
+	ErrorLogFiles []string
+	// ErrorLogCodes are the message codes exported by the error log
+	// collector, others are exported as "other".
+	ErrorLogCodes []string
+	// ModSecurityAuditLog is the ModSecurity audit log to tail, a serial
+	// log or the index of a concurrent one.
+	ModSecurityAuditLog string
+	// ModSecurityStorageDir is the SecAuditLogStorageDir of a concurrent
+	// audit log, empty for a serial log.
+	ModSecurityStorageDir string
+	// ModSecurityMaxRules and ModSecurityMaxVHosts bound the rule_id and
+	// vhost labels of ModSecurity metrics, 0 for no limit.
+	ModSecurityMaxRules  int
+	ModSecurityMaxVHosts int
+	// ProcessPidFile is the httpd PidFile. Without it, the httpd parent is
+	// found from the PIDs on the server-status page.
+	ProcessPidFile string
+	// ProcfsPath is where procfs is mounted.
+	ProcfsPath string
+	// ListenQueuePorts are the ports to export the listen sockets of. They
+	// default to the Listen directives of HTTPDConfigFile, else the port of
+	// ScrapeURI.
+	ListenQueuePorts []int
+	// StatusPageURI is the HTML server-status page. It defaults to ScrapeURI
+	// without ?auto.
+	StatusPageURI string
+	// BalancerRelabeler rewrites, filters and caps proxy balancer worker
+	// series. It may be nil.
+	BalancerRelabeler *BalancerRelabeler
 }
 
 func NewExporter(logger *slog.Logger, config *Config) *Exporter {
+	var directives []configDirective
+	if config.HTTPDConfigFile != "" {
+		var err error
+		if directives, err = parseHTTPDConfig(config.HTTPDConfigFile); err != nil {
+			logger.Error("Error reading Apache config", "file", config.HTTPDConfigFile, "err", err)
+		}
+	}
+
 	return &Exporter{
-		URI:           config.ScrapeURI,
-		hostOverride:  config.HostOverride,
-		customHeaders: config.CustomHeaders,
-		logger:        logger,
+		URI:              config.ScrapeURI,
+		http:             newHTTPClient(config),
+		limits:           config.Limits,
+		configDirectives: directives,
+		relabeler:        config.BalancerRelabeler,
+		logger:           logger,
 		up: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "", "up"),
 			"Could the apache server be reached",
@@ -180,6 +277,20 @@ func NewExporter(logger *slog.Logger, config *Config) *Exporter {
 		},
 			[]string{"balancer", "worker", "status"},
 		),
+		proxyBalancerFlag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "proxy_balancer_worker_flag",
+			Help:      "Apache Proxy Balancer Worker Status Flags",
+		},
+			[]string{"balancer", "worker", "flag"},
+		),
+		proxyBalancerMembers: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "proxy_balancer_members",
+			Help:      "Apache Proxy Balancer Members by state (usable, error, hot_standby)",
+		},
+			[]string{"balancer", "state"},
+		),
 		proxyBalancerElected: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "", "proxy_balancer_accesses_total"),
 			"Apache Proxy Balancer Request Count",
@@ -202,12 +313,52 @@ func NewExporter(logger *slog.Logger, config *Config) *Exporter {
 			"Apache Proxy Balancer Request Count",
 			[]string{"balancer", "worker"}, nil,
 		),
-		client: &http.Client{
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: config.Insecure},
-			},
-		},
-		userAgent: fmt.Sprintf("Prometheus-Apache-Exporter/%s", version.Version),
+		configLimit: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "config", "limit"),
+			"Configured Apache MPM limits",
+			[]string{"directive"}, nil,
+		),
+		workerUtilization: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "worker_utilization_ratio"),
+			"Busy workers as a fraction of MaxRequestWorkers",
+			nil,
+			nil),
+		asyncCapacity: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "async_connection_capacity"),
+			"Maximum concurrent connections the event MPM accepts, (AsyncRequestWorkerFactor + 1) * MaxRequestWorkers",
+			nil,
+			nil),
+		asyncUtilization: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "async_connection_utilization_ratio"),
+			"Open connections as a fraction of the event MPM connection capacity",
+			nil,
+			nil),
+		tlsVersion: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "target_tls", "version"),
+			"TLS version negotiated with the scrape target",
+			[]string{"version"}, nil,
+		),
+		tlsCipherSuite: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "target_tls", "cipher_suite"),
+			"TLS cipher suite negotiated with the scrape target",
+			[]string{"cipher_suite"}, nil,
+		),
+		tlsLeafExpiry: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "target_tls", "leaf_certificate_expiry_timestamp_seconds"),
+			"Expiry of the certificate presented by the scrape target, in seconds since the epoch",
+			[]string{"subject", "issuer", "serial"}, nil,
+		),
+		tlsChainExpiry: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "target_tls", "chain_expiry_timestamp_seconds"),
+			"Earliest expiry of the certificates presented by the scrape target, in seconds since the epoch",
+			nil,
+			nil),
+		tlsChainVerified: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "target_tls", "chain_verified"),
+			"Whether the certificate chain presented by the scrape target verifies against the system roots",
+			nil,
+			nil),
+		tlsSessionCache: newTLSSessionCacheMetrics(),
 	}
 }
 
@@ -230,10 +381,22 @@ func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 	e.connections.Describe(ch)
 	e.scoreboard.Describe(ch)
 	e.proxyBalancerStatus.Describe(ch)
+	e.proxyBalancerFlag.Describe(ch)
+	e.proxyBalancerMembers.Describe(ch)
 	ch <- e.proxyBalancerElected
 	e.proxyBalancerBusy.Describe(ch)
 	ch <- e.proxyBalancerReqSize
 	ch <- e.proxyBalancerRespSize
+	ch <- e.configLimit
+	ch <- e.workerUtilization
+	ch <- e.asyncCapacity
+	ch <- e.asyncUtilization
+	ch <- e.tlsVersion
+	ch <- e.tlsCipherSuite
+	ch <- e.tlsLeafExpiry
+	ch <- e.tlsChainExpiry
+	ch <- e.tlsChainVerified
+	e.tlsSessionCache.describe(ch)
 }
 
 // Split colon separated string into two fields
@@ -281,282 +444,491 @@ func (e *Exporter) updateScoreboard(scoreboard string) {
 	}
 }
 
-func (e *Exporter) collect(ch chan<- prometheus.Metric) error {
-	req, err := http.NewRequest(http.MethodGet, e.URI, nil)
-	if err != nil {
-		return fmt.Errorf("error building scraping request: %w", err)
+// updateProxyBalancerFlags breaks a balancer worker Status such as
+// "Init Dis Drn HcFl" into one series per flag, and counts the worker towards
+// the balancer's usable, error and hot_standby members.
+func (e *Exporter) updateProxyBalancerFlags(balancer, worker, status string) {
+	flags := make(map[string]bool)
+	for _, f := range strings.Fields(status) {
+		flags[f] = true
+	}
+	for _, f := range proxyBalancerWorkerFlags {
+		val := 0.0
+		if flags[f] {
+			val = 1
+		}
+		e.proxyBalancerFlag.WithLabelValues(balancer, worker, f).Set(val)
 	}
 
-	if e.hostOverride != "" {
-		req.Host = e.hostOverride
+	if flags["Err"] || flags["HcFl"] {
+		e.proxyBalancerMembers.WithLabelValues(balancer, "error").Inc()
+	}
+	if flags["Stby"] || flags["Hot"] {
+		e.proxyBalancerMembers.WithLabelValues(balancer, "hot_standby").Inc()
 	}
+	if flags["Ok"] && !flags["Err"] && !flags["HcFl"] && !flags["Dis"] && !flags["Stop"] && !flags["Drn"] &&
+		!flags["Stby"] && !flags["Hot"] && !flags["Spar"] {
+		e.proxyBalancerMembers.WithLabelValues(balancer, "usable").Inc()
+	}
+}
 
-	for k, v := range e.customHeaders {
-		req.Header.Add(k, v)
+func (e *Exporter) collect(ch chan<- prometheus.Metric) error {
+	req, err := e.http.newRequest(e.URI)
+	if err != nil {
+		return err
 	}
 
-	req.Header.Set("User-Agent", e.userAgent)
-	resp, err := e.client.Do(req)
+	resp, err := e.http.client.Do(req)
 	if err != nil {
 		ch <- prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, 0)
 		return fmt.Errorf("error scraping Apache: %w", err)
 	}
 	ch <- prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, 1)
 
-	data, err := io.ReadAll(resp.Body)
-	resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		if err != nil {
-			data = []byte(err.Error())
-		}
-		return fmt.Errorf("status %s (%d): %s", resp.Status, resp.StatusCode, data)
+	if resp.TLS != nil {
+		e.collectTLS(ch, req, resp.TLS)
 	}
 
-	connectionInfo := false
-	version := "UNKNOWN"
-	mpm := "UNKNOWN"
-	balancerName := "UNKNOWN"
-	workerName := "UNKNOWN"
-	cpuUser := 0.0
-	cpuSystem := 0.0
-	cpuFound := false
+	data, err := readResponse(resp)
+	if err != nil {
+		return err
+	}
+	return e.collectStatus(ch, data, nil)
+}
+
+// StatusReport lists the lines of a server-status?auto page that did not
+// produce metrics.
+type StatusReport struct {
+	// Unparsed are the lines with a key the exporter does not know.
+	Unparsed []string
+	// Errors are the fields whose values could not be parsed.
+	Errors []error
+}
+
+// ignoredStatusKeys are keys of server-status?auto that are known but not
+// exported, being informational or derived from other lines.
+var ignoredStatusKeys = map[string]bool{
+	"Server Built":          true,
+	"CurrentTime":           true,
+	"RestartTime":           true,
+	"ServerUptimeSeconds":   true,
+	"ServerUptime":          true,
+	"ReqPerSec":             true,
+	"BytesPerSec":           true,
+	"BytesPerReq":           true,
+	"DurationPerReq":        true,
+	"TLSSessionCacheStatus": true,
+}
+
+// CollectStatus exports the metrics of a captured server-status?auto page as
+// if it had been scraped, without the scrape and TLS metrics, and reports
+// the lines that were not used.
+func (e *Exporter) CollectStatus(ch chan<- prometheus.Metric, data []byte) *StatusReport {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	report := &StatusReport{}
+	e.collectStatus(ch, data, report)
+	return report
+}
+
+// statusScrape is the state carried between the lines of a status page.
+type statusScrape struct {
+	connectionInfo bool
+	version        string
+	mpm            string
+	balancerName   string
+	balancerLabel  string
+	workerName     string
+	keepWorker     bool
+	balancers      *balancerScrape
+	cpuUser        float64
+	cpuSystem      float64
+	cpuFound       bool
+	busyWorkers    float64
+	busyFound      bool
+	connsTotal     float64
+}
+
+// collectStatus exports the metrics of a status page. Without a report it
+// stops at the first value that cannot be parsed, with one it records the
+// lines not used and carries on.
+func (e *Exporter) collectStatus(ch chan<- prometheus.Metric, data []byte, report *StatusReport) error {
+	s := &statusScrape{
+		version:       "UNKNOWN",
+		mpm:           "UNKNOWN",
+		balancerName:  "UNKNOWN",
+		balancerLabel: "UNKNOWN",
+		workerName:    "UNKNOWN",
+		keepWorker:    true,
+		balancers:     e.relabeler.newScrape(),
+	}
 	e.proxyBalancerStatus.Reset()
+	e.proxyBalancerFlag.Reset()
+	e.proxyBalancerMembers.Reset()
 	e.proxyBalancerBusy.Reset()
 
 	scanner := bufio.NewScanner(bytes.NewReader(data))
 
-	for scanner.Scan() {
-		key, v := splitkv(scanner.Text())
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		// The page of Apache 2.4 starts with the server name.
+		if n == 1 && !strings.Contains(line, ":") {
+			continue
+		}
+		key, v := splitkv(line)
+		known, err := e.collectField(ch, s, key, v)
 		if err != nil {
+			err = fmt.Errorf("invalid %s on line %d: %w", key, n, err)
+			if report == nil {
+				return err
+			}
+			report.Errors = append(report.Errors, err)
 			continue
 		}
+		if !known && report != nil && key != "" && !ignoredStatusKeys[key] {
+			report.Unparsed = append(report.Unparsed, line)
+		}
+	}
 
-		switch {
-		case key == "ServerVersion":
-			version = v
-			tmpstr := strings.Split(v, "/")[1]
-			tmpstr = strings.Split(tmpstr, " ")[0]
-			vparts := strings.Split(tmpstr, ".")
-			tmpstr = fmt.Sprintf("%s.%02s%03s", vparts[0], vparts[1], vparts[2])
+	if s.cpuFound {
+		ch <- prometheus.MustNewConstMetric(e.cpuTotal, prometheus.CounterValue, 1000*s.cpuUser, "user")
+		ch <- prometheus.MustNewConstMetric(e.cpuTotal, prometheus.CounterValue, 1000*s.cpuSystem, "system")
+	}
 
-			val, err := strconv.ParseFloat(tmpstr, 64)
-			if err != nil {
-				return err
-			}
-			ch <- prometheus.MustNewConstMetric(e.apacheVersion, prometheus.GaugeValue, val)
-		case key == "ServerMPM":
-			mpm = v
-		case key == "ParentServerConfigGeneration":
-			val, err := strconv.ParseFloat(v, 64)
-			if err != nil {
-				return err
-			}
-			e.generation.WithLabelValues("config").Set(val)
-		case key == "ParentServerMPMGeneration":
-			val, err := strconv.ParseFloat(v, 64)
-			if err != nil {
-				return err
-			}
-			e.generation.WithLabelValues("mpm").Set(val)
-		case key == "Load1":
-			val, err := strconv.ParseFloat(v, 64)
-			if err != nil {
-				return err
-			}
-			e.load.WithLabelValues("1min").Set(val)
-		case key == "Load5":
-			val, err := strconv.ParseFloat(v, 64)
-			if err != nil {
-				return err
-			}
-			e.load.WithLabelValues("5min").Set(val)
-		case key == "Load15":
-			val, err := strconv.ParseFloat(v, 64)
-			if err != nil {
-				return err
-			}
-			e.load.WithLabelValues("15min").Set(val)
-		case key == "Total Accesses":
-			val, err := strconv.ParseFloat(v, 64)
-			if err != nil {
-				return err
-			}
-			ch <- prometheus.MustNewConstMetric(e.accessesTotal, prometheus.CounterValue, val)
-		case key == "Total kBytes":
-			val, err := strconv.ParseFloat(v, 64)
-			if err != nil {
-				return err
-			}
-			ch <- prometheus.MustNewConstMetric(e.kBytesTotal, prometheus.CounterValue, val)
-		case key == "Total Duration":
-			val, err := strconv.ParseFloat(v, 64)
-			if err != nil {
-				return err
-			}
-			ch <- prometheus.MustNewConstMetric(e.durationTotal, prometheus.CounterValue, val)
-		case key == "CPUUser":
-			val, err := strconv.ParseFloat(v, 64)
-			if err != nil {
-				return err
-			}
+	e.apacheInfo.WithLabelValues(s.version, s.mpm).Set(1)
 
-			cpuUser += val
-			cpuFound = true
-		case key == "CPUChildrenUser":
-			val, err := strconv.ParseFloat(v, 64)
-			if err != nil {
-				return err
-			}
+	e.apacheInfo.Collect(ch)
+	e.generation.Collect(ch)
+	e.load.Collect(ch)
+	e.cpuload.Collect(ch)
+	e.workers.Collect(ch)
+	e.processes.Collect(ch)
+	if s.connectionInfo {
+		e.connections.Collect(ch)
+	}
 
-			cpuUser += val
-			cpuFound = true
-		case key == "CPUSystem":
-			val, err := strconv.ParseFloat(v, 64)
-			if err != nil {
-				return err
-			}
+	e.proxyBalancerStatus.Collect(ch)
+	e.proxyBalancerFlag.Collect(ch)
+	e.proxyBalancerMembers.Collect(ch)
+	e.proxyBalancerBusy.Collect(ch)
 
-			cpuSystem += val
-			cpuFound = true
-		case key == "CPUChildrenSystem":
-			val, err := strconv.ParseFloat(v, 64)
-			if err != nil {
-				return err
-			}
+	e.collectLimits(ch, s.mpm, s.busyFound, s.busyWorkers, s.connectionInfo, s.connsTotal)
 
-			cpuSystem += val
-			cpuFound = true
-		case key == "CPULoad":
-			val, err := strconv.ParseFloat(v, 64)
-			if err != nil {
-				return err
-			}
-			e.cpuload.Set(val)
-		case key == "Uptime":
-			val, err := strconv.ParseFloat(v, 64)
-			if err != nil {
-				return err
-			}
-			ch <- prometheus.MustNewConstMetric(e.uptime, prometheus.CounterValue, val)
-		case key == "BusyWorkers":
-			val, err := strconv.ParseFloat(v, 64)
-			if err != nil {
-				return err
-			}
-			e.workers.WithLabelValues("busy").Set(val)
-		case key == "IdleWorkers":
-			val, err := strconv.ParseFloat(v, 64)
-			if err != nil {
-				return err
-			}
-			e.workers.WithLabelValues("idle").Set(val)
-		case key == "Processes":
-			val, err := strconv.ParseFloat(v, 64)
-			if err != nil {
-				return err
-			}
-			e.processes.WithLabelValues("all").Set(val)
-		case key == "Stopping":
-			val, err := strconv.ParseFloat(v, 64)
-			if err != nil {
-				return err
+	return nil
+}
+
+// collectField exports a line of a status page, returning whether its key
+// is one the exporter uses.
+func (e *Exporter) collectField(ch chan<- prometheus.Metric, s *statusScrape, key, v string) (bool, error) {
+	switch {
+	case key == "ServerVersion":
+		s.version = v
+		val, err := parseServerVersion(v)
+		if err != nil {
+			return true, err
+		}
+		ch <- prometheus.MustNewConstMetric(e.apacheVersion, prometheus.GaugeValue, val)
+	case key == "ServerMPM":
+		s.mpm = v
+	case key == "ParentServerConfigGeneration":
+		val, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return true, err
+		}
+		e.generation.WithLabelValues("config").Set(val)
+	case key == "ParentServerMPMGeneration":
+		val, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return true, err
+		}
+		e.generation.WithLabelValues("mpm").Set(val)
+	case key == "Load1":
+		val, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return true, err
+		}
+		e.load.WithLabelValues("1min").Set(val)
+	case key == "Load5":
+		val, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return true, err
+		}
+		e.load.WithLabelValues("5min").Set(val)
+	case key == "Load15":
+		val, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return true, err
+		}
+		e.load.WithLabelValues("15min").Set(val)
+	case key == "Total Accesses":
+		val, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return true, err
+		}
+		ch <- prometheus.MustNewConstMetric(e.accessesTotal, prometheus.CounterValue, val)
+	case key == "Total kBytes":
+		val, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return true, err
+		}
+		ch <- prometheus.MustNewConstMetric(e.kBytesTotal, prometheus.CounterValue, val)
+	case key == "Total Duration":
+		val, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return true, err
+		}
+		ch <- prometheus.MustNewConstMetric(e.durationTotal, prometheus.CounterValue, val)
+	case key == "CPUUser":
+		val, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return true, err
+		}
+
+		s.cpuUser += val
+		s.cpuFound = true
+	case key == "CPUChildrenUser":
+		val, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return true, err
+		}
+
+		s.cpuUser += val
+		s.cpuFound = true
+	case key == "CPUSystem":
+		val, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return true, err
+		}
+
+		s.cpuSystem += val
+		s.cpuFound = true
+	case key == "CPUChildrenSystem":
+		val, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return true, err
+		}
+
+		s.cpuSystem += val
+		s.cpuFound = true
+	case key == "CPULoad":
+		val, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return true, err
+		}
+		e.cpuload.Set(val)
+	case key == "Uptime":
+		val, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return true, err
+		}
+		ch <- prometheus.MustNewConstMetric(e.uptime, prometheus.CounterValue, val)
+	case key == "BusyWorkers":
+		val, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return true, err
+		}
+		e.workers.WithLabelValues("busy").Set(val)
+		s.busyWorkers = val
+		s.busyFound = true
+	case key == "IdleWorkers":
+		val, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return true, err
+		}
+		e.workers.WithLabelValues("idle").Set(val)
+	case key == "Processes":
+		val, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return true, err
+		}
+		e.processes.WithLabelValues("all").Set(val)
+	case key == "Stopping":
+		val, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return true, err
+		}
+		e.processes.WithLabelValues("stopping").Set(val)
+	case key == "ConnsTotal":
+		val, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return true, err
+		}
+		e.connections.WithLabelValues("total").Set(val)
+		s.connsTotal = val
+		s.connectionInfo = true
+	case key == "ConnsAsyncWriting":
+		val, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return true, err
+		}
+		e.connections.WithLabelValues("writing").Set(val)
+		s.connectionInfo = true
+	case key == "ConnsAsyncKeepAlive":
+		val, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return true, err
+		}
+		e.connections.WithLabelValues("keepalive").Set(val)
+		s.connectionInfo = true
+	case key == "ConnsAsyncClosing":
+		val, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return true, err
+		}
+		e.connections.WithLabelValues("closing").Set(val)
+		s.connectionInfo = true
+	case strings.HasPrefix(key, "Cache"):
+		return e.tlsSessionCache.collect(ch, key, v)
+	case key == "Scoreboard":
+		e.updateScoreboard(v)
+		e.scoreboard.Collect(ch)
+
+	//ProxyBalancer[0]Name: balancer://sid2021
+	//ProxyBalancer[0]Worker[0]Name: https://z-app-01:9143
+	//ProxyBalancer[0]Worker[0]Status: Init Ok
+	//ProxyBalancer[0]Worker[0]Elected: 5808
+	//...
+	case reProxyBalName.MatchString(key):
+		s.balancerName = v
+		if label, keep := s.balancers.balancer(v); keep {
+			for _, state := range []string{"usable", "error", "hot_standby"} {
+				e.proxyBalancerMembers.WithLabelValues(label, state)
 			}
-			e.processes.WithLabelValues("stopping").Set(val)
-		case key == "ConnsTotal":
+		}
+	case reProxyBalWorker.MatchString(key):
+		key := reProxyBalWorker.FindStringSubmatch(key)[1]
+		if key != "Name" && !s.keepWorker {
+			return true, nil
+		}
+		switch key {
+		case "Name":
+			s.balancerLabel, s.workerName, s.keepWorker = s.balancers.worker(s.balancerName, v)
+		case "Status":
+			e.proxyBalancerStatus.WithLabelValues(s.balancerLabel, s.workerName, v).Set(1)
+			e.updateProxyBalancerFlags(s.balancerLabel, s.workerName, v)
+		case "Elected":
 			val, err := strconv.ParseFloat(v, 64)
 			if err != nil {
-				return err
+				return true, err
 			}
-			e.connections.WithLabelValues("total").Set(val)
-			connectionInfo = true
-		case key == "ConnsAsyncWriting":
+			ch <- prometheus.MustNewConstMetric(e.proxyBalancerElected, prometheus.CounterValue, val, s.balancerLabel, s.workerName)
+		case "Busy":
 			val, err := strconv.ParseFloat(v, 64)
 			if err != nil {
-				return err
+				return true, err
 			}
-			e.connections.WithLabelValues("writing").Set(val)
-			connectionInfo = true
-		case key == "ConnsAsyncKeepAlive":
-			val, err := strconv.ParseFloat(v, 64)
+			e.proxyBalancerBusy.WithLabelValues(s.balancerLabel, s.workerName).Set(val)
+		case "Sent":
+			val, err := strconv.ParseFloat(strings.TrimRight(v, "kK"), 64)
 			if err != nil {
-				return err
+				return true, err
 			}
-			e.connections.WithLabelValues("keepalive").Set(val)
-			connectionInfo = true
-		case key == "ConnsAsyncClosing":
-			val, err := strconv.ParseFloat(v, 64)
+			ch <- prometheus.MustNewConstMetric(e.proxyBalancerReqSize, prometheus.CounterValue, val, s.balancerLabel, s.workerName)
+		case "Rcvd":
+			val, err := strconv.ParseFloat(strings.TrimRight(v, "kK"), 64)
 			if err != nil {
-				return err
-			}
-			e.connections.WithLabelValues("closing").Set(val)
-			connectionInfo = true
-		case key == "Scoreboard":
-			e.updateScoreboard(v)
-			e.scoreboard.Collect(ch)
-
-		//ProxyBalancer[0]Name: balancer://sid2021
-		//ProxyBalancer[0]Worker[0]Name: https://z-app-01:9143
-		//ProxyBalancer[0]Worker[0]Status: Init Ok
-		//ProxyBalancer[0]Worker[0]Elected: 5808
-		//...
-		case reProxyBalName.MatchString(key):
-			balancerName = v
-		case reProxyBalWorker.MatchString(key):
-			key := reProxyBalWorker.FindStringSubmatch(key)[1]
-			switch key {
-			case "Name":
-				workerName = v
-			case "Status":
-				e.proxyBalancerStatus.WithLabelValues(balancerName, workerName, v).Set(1)
-			case "Elected":
-				val, err := strconv.ParseFloat(v, 64)
-				if err != nil {
-					return err
-				}
-				ch <- prometheus.MustNewConstMetric(e.proxyBalancerElected, prometheus.CounterValue, val, balancerName, workerName)
-			case "Busy":
-				val, err := strconv.ParseFloat(v, 64)
-				if err != nil {
-					return err
-				}
-				e.proxyBalancerBusy.WithLabelValues(balancerName, workerName).Set(val)
-			case "Sent":
-				val, err := strconv.ParseFloat(strings.TrimRight(v, "kK"), 64)
-				if err != nil {
-					return err
-				}
-				ch <- prometheus.MustNewConstMetric(e.proxyBalancerReqSize, prometheus.CounterValue, val, balancerName, workerName)
-			case "Rcvd":
-				val, err := strconv.ParseFloat(strings.TrimRight(v, "kK"), 64)
-				if err != nil {
-					return err
-				}
-				ch <- prometheus.MustNewConstMetric(e.proxyBalancerRespSize, prometheus.CounterValue, val, balancerName, workerName)
+				return true, err
 			}
+			ch <- prometheus.MustNewConstMetric(e.proxyBalancerRespSize, prometheus.CounterValue, val, s.balancerLabel, s.workerName)
 		}
+	default:
+		return false, nil
 	}
+	return true, nil
+}
 
-	if cpuFound {
-		ch <- prometheus.MustNewConstMetric(e.cpuTotal, prometheus.CounterValue, 1000*cpuUser, "user")
-		ch <- prometheus.MustNewConstMetric(e.cpuTotal, prometheus.CounterValue, 1000*cpuSystem, "system")
+// parseServerVersion returns a ServerVersion such as "Apache/2.4.23 (Unix)"
+// as a number, 2.04023.
+func parseServerVersion(v string) (float64, error) {
+	_, product, ok := strings.Cut(v, "/")
+	vparts := strings.Split(strings.Split(product, " ")[0], ".")
+	if !ok || len(vparts) < 3 {
+		return 0, fmt.Errorf("no major, minor and patch version in %q", v)
 	}
+	return strconv.ParseFloat(fmt.Sprintf("%s.%02s%03s", vparts[0], vparts[1], vparts[2]), 64)
+}
 
-	e.apacheInfo.WithLabelValues(version, mpm).Set(1)
+// collectTLS exports what was negotiated and presented during the handshake
+// with the scrape target. The chain is verified here rather than by the
+// client, so the result is available even when --insecure is set.
+func (e *Exporter) collectTLS(ch chan<- prometheus.Metric, req *http.Request, state *tls.ConnectionState) {
+	ch <- prometheus.MustNewConstMetric(e.tlsVersion, prometheus.GaugeValue, 1, tls.VersionName(state.Version))
+	ch <- prometheus.MustNewConstMetric(e.tlsCipherSuite, prometheus.GaugeValue, 1, tls.CipherSuiteName(state.CipherSuite))
 
-	e.apacheInfo.Collect(ch)
-	e.generation.Collect(ch)
-	e.load.Collect(ch)
-	e.cpuload.Collect(ch)
-	e.workers.Collect(ch)
-	e.processes.Collect(ch)
-	if connectionInfo {
-		e.connections.Collect(ch)
+	if len(state.PeerCertificates) == 0 {
+		return
+	}
+	leaf := state.PeerCertificates[0]
+	ch <- prometheus.MustNewConstMetric(e.tlsLeafExpiry, prometheus.GaugeValue, float64(leaf.NotAfter.Unix()),
+		leaf.Subject.String(), leaf.Issuer.String(), strings.ToUpper(leaf.SerialNumber.Text(16)))
+
+	earliest := leaf.NotAfter
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		if cert.NotAfter.Before(earliest) {
+			earliest = cert.NotAfter
+		}
+		intermediates.AddCert(cert)
 	}
+	ch <- prometheus.MustNewConstMetric(e.tlsChainExpiry, prometheus.GaugeValue, float64(earliest.Unix()))
 
-	e.proxyBalancerStatus.Collect(ch)
-	e.proxyBalancerBusy.Collect(ch)
+	verified := 0.0
+	if _, err := leaf.Verify(x509.VerifyOptions{DNSName: e.tlsVerifyHost(req), Roots: e.tlsRoots, Intermediates: intermediates}); err == nil {
+		verified = 1
+	} else {
+		e.logger.Debug("Scrape target certificate does not verify", "err", err)
+	}
+	ch <- prometheus.MustNewConstMetric(e.tlsChainVerified, prometheus.GaugeValue, verified)
+}
 
-	return nil
+// tlsVerifyHost returns the name the certificate of the scrape target must be
+// valid for: the host of the Host override, which may be a bracketed IPv6
+// address and may have no port, else that of the scrape URI.
+func (e *Exporter) tlsVerifyHost(req *http.Request) string {
+	if e.http.hostOverride == "" {
+		return req.URL.Hostname()
+	}
+	host, _, err := net.SplitHostPort(e.http.hostOverride)
+	if err != nil {
+		return strings.TrimSuffix(strings.TrimPrefix(e.http.hostOverride, "["), "]")
+	}
+	return host
+}
+
+// collectLimits exports the configured MPM limits and how much of them the
+// live worker and connection counts use.
+func (e *Exporter) collectLimits(ch chan<- prometheus.Metric, mpm string, busyFound bool, busyWorkers float64, connectionInfo bool, connsTotal float64) {
+	fileLimits, err := mpmLimits(e.configDirectives, mpm)
+	if err != nil {
+		e.logger.Error("Error reading Apache config limits", "err", err)
+	}
+	limits := fileLimits.merge(e.limits)
+
+	for directive, val := range limits.directives() {
+		ch <- prometheus.MustNewConstMetric(e.configLimit, prometheus.GaugeValue, val, directive)
+	}
+
+	maxWorkers := limits.maxRequestWorkers()
+	if maxWorkers == 0 {
+		return
+	}
+	if busyFound {
+		ch <- prometheus.MustNewConstMetric(e.workerUtilization, prometheus.GaugeValue, busyWorkers/maxWorkers)
+	}
+
+	if mpm != "event" {
+		return
+	}
+	factor := limits.AsyncRequestWorkerFactor
+	if factor == 0 {
+		// The event MPM default.
+		factor = 2
+	}
+	capacity := (factor + 1) * maxWorkers
+	ch <- prometheus.MustNewConstMetric(e.asyncCapacity, prometheus.GaugeValue, capacity)
+	if connectionInfo {
+		ch <- prometheus.MustNewConstMetric(e.asyncUtilization, prometheus.GaugeValue, connsTotal/capacity)
+	}
 }
 
 // Collect implements Prometheus.Collector.
@@ -0,0 +1,180 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+)
+
+const proxyFlagsStatus = `ServerVersion: Apache/2.4.62 (Unix)
+ServerMPM: event
+BusyWorkers: 1
+IdleWorkers: 74
+Scoreboard: _W___
+ProxyBalancer[0]Name: balancer://app
+ProxyBalancer[0]Worker[0]Name: http://app-01:8080
+ProxyBalancer[0]Worker[0]Status: Init Ok
+ProxyBalancer[0]Worker[1]Name: http://app-02:8080
+ProxyBalancer[0]Worker[1]Status: Init Dis Drn HcFl
+ProxyBalancer[0]Worker[2]Name: http://app-03:8080
+ProxyBalancer[0]Worker[2]Status: Init Stby Ok
+ProxyBalancer[0]Worker[3]Name: http://app-04:8080
+ProxyBalancer[0]Worker[3]Status: Init Err
+`
+
+func newTestExporter(t *testing.T, status string, config *Config) *Exporter {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(status))
+	}))
+	t.Cleanup(server.Close)
+	config.ScrapeURI = server.URL
+	return NewExporter(promslog.NewNopLogger(), config)
+}
+
+func TestProxyBalancerFlags(t *testing.T) {
+	e := newTestExporter(t, proxyFlagsStatus, &Config{})
+
+	expected := `
+# HELP apache_proxy_balancer_members Apache Proxy Balancer Members by state (usable, error, hot_standby)
+# TYPE apache_proxy_balancer_members gauge
+apache_proxy_balancer_members{balancer="balancer://app",state="error"} 2
+apache_proxy_balancer_members{balancer="balancer://app",state="hot_standby"} 1
+apache_proxy_balancer_members{balancer="balancer://app",state="usable"} 1
+`
+	if err := testutil.CollectAndCompare(e, strings.NewReader(expected), "apache_proxy_balancer_members"); err != nil {
+		t.Error(err)
+	}
+
+	set := map[string]map[string]bool{
+		"http://app-01:8080": {"Init": true, "Ok": true},
+		"http://app-02:8080": {"Init": true, "Dis": true, "Drn": true, "HcFl": true},
+		"http://app-03:8080": {"Init": true, "Stby": true, "Ok": true},
+		"http://app-04:8080": {"Init": true, "Err": true},
+	}
+	var sb strings.Builder
+	sb.WriteString("# HELP apache_proxy_balancer_worker_flag Apache Proxy Balancer Worker Status Flags\n")
+	sb.WriteString("# TYPE apache_proxy_balancer_worker_flag gauge\n")
+	for _, worker := range []string{"http://app-01:8080", "http://app-02:8080", "http://app-03:8080", "http://app-04:8080"} {
+		for _, flag := range proxyBalancerWorkerFlags {
+			val := 0
+			if set[worker][flag] {
+				val = 1
+			}
+			fmt.Fprintf(&sb, "apache_proxy_balancer_worker_flag{balancer=\"balancer://app\",flag=%q,worker=%q} %d\n", flag, worker, val)
+		}
+	}
+	if err := testutil.CollectAndCompare(e, strings.NewReader(sb.String()), "apache_proxy_balancer_worker_flag"); err != nil {
+		t.Error(err)
+	}
+}
+
+const tlsSessionCacheStatus = `ServerVersion: Apache/2.4.62 (Unix)
+ServerMPM: event
+TLSSessionCacheStatus
+CacheType: SHMCB
+CacheSharedMemory: 512000
+CacheCurrentEntries: 212
+CacheSubcaches: 32
+CacheIndexesPerSubcaches: 88
+CacheIndexUsage: 7%
+CacheUsage: 12%
+CacheStoreCount: 4012
+CacheReplaceCount: 3
+CacheExpireCount: 3790
+CacheDiscardCount: 7
+CacheRetrieveHitCount: 1520
+CacheRetrieveMissCount: 480
+CacheRemoveHitCount: 2
+CacheRemoveMissCount: 1
+`
+
+func TestTLSSessionCache(t *testing.T) {
+	e := newTestExporter(t, tlsSessionCacheStatus, &Config{})
+
+	expected := `
+# HELP apache_tls_session_cache_index_usage_ratio Fraction of TLS session cache indexes in use
+# TYPE apache_tls_session_cache_index_usage_ratio gauge
+apache_tls_session_cache_index_usage_ratio 0.07
+# HELP apache_tls_session_cache_info mod_ssl TLS session cache type
+# TYPE apache_tls_session_cache_info gauge
+apache_tls_session_cache_info{type="SHMCB"} 1
+# HELP apache_tls_session_cache_retrieves_total TLS session cache lookups by result (hit, miss)
+# TYPE apache_tls_session_cache_retrieves_total counter
+apache_tls_session_cache_retrieves_total{result="hit"} 1520
+apache_tls_session_cache_retrieves_total{result="miss"} 480
+# HELP apache_tls_session_cache_shared_memory_bytes Size of the TLS session cache shared memory segment
+# TYPE apache_tls_session_cache_shared_memory_bytes gauge
+apache_tls_session_cache_shared_memory_bytes 512000
+# HELP apache_tls_session_cache_usage_ratio Fraction of TLS session cache data space in use
+# TYPE apache_tls_session_cache_usage_ratio gauge
+apache_tls_session_cache_usage_ratio 0.12
+`
+	if err := testutil.CollectAndCompare(e, strings.NewReader(expected),
+		"apache_tls_session_cache_info", "apache_tls_session_cache_shared_memory_bytes",
+		"apache_tls_session_cache_index_usage_ratio", "apache_tls_session_cache_usage_ratio",
+		"apache_tls_session_cache_retrieves_total",
+	); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTargetTLS(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(proxyFlagsStatus))
+	}))
+	server.TLS = &tls.Config{
+		MaxVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	// The test certificate is valid for 127.0.0.1, ::1 and example.com.
+	tests := []struct {
+		hostOverride string
+		verified     int
+	}{
+		{"", 1},
+		{"example.com", 1},
+		{"example.com:443", 1},
+		{"[::1]:443", 1},
+		{"[::1]", 1},
+		{"apache.test:8443", 0},
+	}
+	for _, test := range tests {
+		e := NewExporter(promslog.NewNopLogger(), &Config{ScrapeURI: server.URL, HostOverride: test.hostOverride, Insecure: true})
+		e.tlsRoots = roots
+
+		expected := fmt.Sprintf(`
+# HELP apache_target_tls_chain_verified Whether the certificate chain presented by the scrape target verifies against the system roots
+# TYPE apache_target_tls_chain_verified gauge
+apache_target_tls_chain_verified %d
+# HELP apache_target_tls_cipher_suite TLS cipher suite negotiated with the scrape target
+# TYPE apache_target_tls_cipher_suite gauge
+apache_target_tls_cipher_suite{cipher_suite="TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"} 1
+# HELP apache_target_tls_version TLS version negotiated with the scrape target
+# TYPE apache_target_tls_version gauge
+apache_target_tls_version{version="TLS 1.2"} 1
+`, test.verified)
+		if err := testutil.CollectAndCompare(e, strings.NewReader(expected),
+			"apache_target_tls_chain_verified", "apache_target_tls_cipher_suite", "apache_target_tls_version"); err != nil {
+			t.Errorf("host override %q: %s", test.hostOverride, err)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// vhostInfo describes a <VirtualHost> as exported by apache_vhost_info.
+type vhostInfo struct {
+	serverName   string
+	port         string
+	documentRoot string
+	ssl          bool
+}
+
+// configInventory is the set of vhosts, listeners and modules found in an
+// httpd config.
+type configInventory struct {
+	vhosts  []vhostInfo
+	listens []string
+	modules []string
+}
+
+// newConfigInventory builds the inventory from parsed directives.
+func newConfigInventory(directives []configDirective) *configInventory {
+	inv := &configInventory{}
+	vhosts := make(map[*configVirtualHost]*vhostInfo)
+	var order []*configVirtualHost
+
+	for _, d := range directives {
+		switch strings.ToLower(d.name) {
+		case "listen":
+			if len(d.args) > 0 {
+				inv.listens = append(inv.listens, d.args[0])
+			}
+			continue
+		case "loadmodule":
+			if len(d.args) > 0 {
+				inv.modules = append(inv.modules, d.args[0])
+			}
+			continue
+		}
+
+		if d.vhost == nil {
+			continue
+		}
+		v, ok := vhosts[d.vhost]
+		if !ok {
+			v = &vhostInfo{}
+			if len(d.vhost.addrs) > 0 {
+				if _, port, err := net.SplitHostPort(d.vhost.addrs[0]); err == nil {
+					v.port = port
+				}
+			}
+			vhosts[d.vhost] = v
+			order = append(order, d.vhost)
+		}
+		switch strings.ToLower(d.name) {
+		case "servername":
+			if len(d.args) > 0 {
+				v.serverName = d.args[0]
+				if host, port, err := net.SplitHostPort(v.serverName); err == nil {
+					v.serverName = host
+					if v.port == "" {
+						v.port = port
+					}
+				}
+			}
+		case "documentroot":
+			if len(d.args) > 0 {
+				v.documentRoot = d.args[0]
+			}
+		case "sslengine":
+			if len(d.args) > 0 {
+				v.ssl = strings.EqualFold(d.args[0], "on")
+			}
+		}
+	}
+
+	for _, vh := range order {
+		inv.vhosts = append(inv.vhosts, *vhosts[vh])
+	}
+	return inv
+}
+
+// ConfigInventoryCollector exports the vhosts, listeners and modules
+// configured in an httpd config file and the files it includes.
+type ConfigInventoryCollector struct {
+	path   string
+	mutex  sync.Mutex
+	logger *slog.Logger
+
+	parseSuccess *prometheus.Desc
+	vhostInfo    *prometheus.Desc
+	listenInfo   *prometheus.Desc
+	moduleInfo   *prometheus.Desc
+}
+
+func NewConfigInventoryCollector(logger *slog.Logger, config *Config) *ConfigInventoryCollector {
+	return &ConfigInventoryCollector{
+		path:   config.HTTPDConfigFile,
+		logger: logger,
+		parseSuccess: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "config", "parse_success"),
+			"Whether the Apache config could be parsed",
+			nil,
+			nil),
+		vhostInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "vhost_info"),
+			"Virtual hosts configured in Apache",
+			[]string{"server_name", "port", "document_root", "ssl"}, nil,
+		),
+		listenInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "listen_info"),
+			"Addresses Apache is configured to listen on",
+			[]string{"address"}, nil,
+		),
+		moduleInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "config", "module_info"),
+			"Modules loaded by the Apache config",
+			[]string{"module"}, nil,
+		),
+	}
+}
+
+// Describe implements Prometheus.Collector.
+func (c *ConfigInventoryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.parseSuccess
+	ch <- c.vhostInfo
+	ch <- c.listenInfo
+	ch <- c.moduleInfo
+}
+
+// Collect implements Prometheus.Collector.
+func (c *ConfigInventoryCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	directives, err := parseHTTPDConfig(c.path)
+	if err != nil {
+		c.logger.Error("Error parsing Apache config", "file", c.path, "err", err)
+		ch <- prometheus.MustNewConstMetric(c.parseSuccess, prometheus.GaugeValue, 0)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.parseSuccess, prometheus.GaugeValue, 1)
+
+	inv := newConfigInventory(directives)
+
+	// The same vhost, listener or module may be configured more than once;
+	// each is only exported once.
+	seen := make(map[vhostInfo]bool)
+	for _, v := range inv.vhosts {
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		ssl := "false"
+		if v.ssl {
+			ssl = "true"
+		}
+		ch <- prometheus.MustNewConstMetric(c.vhostInfo, prometheus.GaugeValue, 1, v.serverName, v.port, v.documentRoot, ssl)
+	}
+	for _, addr := range dedupe(inv.listens) {
+		ch <- prometheus.MustNewConstMetric(c.listenInfo, prometheus.GaugeValue, 1, addr)
+	}
+	for _, module := range dedupe(inv.modules) {
+		ch <- prometheus.MustNewConstMetric(c.moduleInfo, prometheus.GaugeValue, 1, module)
+	}
+}
+
+// dedupe returns the distinct values of s in their original order.
+func dedupe(s []string) []string {
+	seen := make(map[string]bool, len(s))
+	var out []string
+	for _, v := range s {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+)
+
+func TestConfigInventory(t *testing.T) {
+	c := NewConfigInventoryCollector(promslog.NewNopLogger(), &Config{HTTPDConfigFile: "testdata/httpd/conf/httpd.conf"})
+
+	expected := `
+# HELP apache_config_module_info Modules loaded by the Apache config
+# TYPE apache_config_module_info gauge
+apache_config_module_info{module="mpm_event_module"} 1
+apache_config_module_info{module="proxy_module"} 1
+apache_config_module_info{module="ssl_module"} 1
+apache_config_module_info{module="status_module"} 1
+# HELP apache_config_parse_success Whether the Apache config could be parsed
+# TYPE apache_config_parse_success gauge
+apache_config_parse_success 1
+# HELP apache_listen_info Addresses Apache is configured to listen on
+# TYPE apache_listen_info gauge
+apache_listen_info{address="443"} 1
+apache_listen_info{address="80"} 1
+# HELP apache_vhost_info Virtual hosts configured in Apache
+# TYPE apache_vhost_info gauge
+apache_vhost_info{document_root="/srv/www/example",port="80",server_name="www.example.com",ssl="false"} 1
+apache_vhost_info{document_root="/srv/www/secure",port="443",server_name="secure.example.com",ssl="true"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConfigInventoryParseFailure(t *testing.T) {
+	c := NewConfigInventoryCollector(promslog.NewNopLogger(), &Config{HTTPDConfigFile: "testdata/httpd/conf/missing.conf"})
+
+	expected := `
+# HELP apache_config_parse_success Whether the Apache config could be parsed
+# TYPE apache_config_parse_success gauge
+apache_config_parse_success 0
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"log/slog"
+	"regexp"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// Matches the time and [module:level] prefix of an error log line in
+	// the default ErrorLogFormat, e.g.
+	// "[Mon Oct 19 10:15:02.123456 2026] [mpm_event:error] [pid 4211:tid 1] AH00484: ...".
+	// Apache 2.2 logs only the level, e.g. "[Mon Oct 19 10:15:02 2026] [error] ...".
+	reErrorLogPrefix = regexp.MustCompile(`^\[[^\]]*\] \[(?:([^:\]\s]*):)?([a-z0-9]+)\]`)
+	// Matches the message code, which may follow an OS error and the client,
+	// e.g. "(70007)The timeout specified has expired: [client 192.0.2.1:5678] AH01102: ...".
+	reErrorLogCode = regexp.MustCompile(`\bAH\d{5}\b`)
+)
+
+// DefaultErrorLogCodes are the message codes exported by default: workers or
+// the scoreboard running out, crashing children, proxy backend failures,
+// FastCGI errors, redirect loops and denied clients.
+var DefaultErrorLogCodes = []string{
+	"AH00161", "AH00484", "AH00288", "AH03490", "AH00052", "AH00060",
+	"AH01102", "AH01114", "AH00957", "AH00959", "AH01097",
+	"AH01067", "AH01071", "AH00124", "AH01630", "AH01797",
+}
+
+// ErrorLogCollector counts the messages of Apache error logs by module, level
+// and message code.
+type ErrorLogCollector struct {
+	codes   map[string]bool
+	logger  *slog.Logger
+	tailers []*tailer
+
+	messages *prometheus.CounterVec
+}
+
+// NewErrorLogCollector returns a collector tailing config.ErrorLogFiles until
+// Close is called. Message codes not in config.ErrorLogCodes are counted as
+// "other", messages without a code with an empty code.
+func NewErrorLogCollector(logger *slog.Logger, config *Config) *ErrorLogCollector {
+	c := &ErrorLogCollector{
+		codes:  make(map[string]bool),
+		logger: logger,
+		messages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "error_log_messages_total",
+			Help:      "Messages written to the error log by module, level and message code",
+		},
+			[]string{"module", "level", "code"},
+		),
+	}
+	for _, code := range config.ErrorLogCodes {
+		c.codes[code] = true
+	}
+
+	for _, path := range config.ErrorLogFiles {
+		t := newTailer(logger, path, c.handleLine)
+		c.tailers = append(c.tailers, t)
+		go t.run()
+	}
+	return c
+}
+
+// Close stops tailing the error logs.
+func (c *ErrorLogCollector) Close() {
+	for _, t := range c.tailers {
+		t.close()
+	}
+}
+
+// handleLine counts an error log line. Lines not starting with a time and
+// level, e.g. output of CGI scripts, are ignored.
+func (c *ErrorLogCollector) handleLine(line string) {
+	m := reErrorLogPrefix.FindStringSubmatch(line)
+	if m == nil {
+		return
+	}
+	code := reErrorLogCode.FindString(line[len(m[0]):])
+	if code != "" && !c.codes[code] {
+		code = "other"
+	}
+	c.messages.WithLabelValues(m[1], m[2], code).Inc()
+}
+
+// Describe implements Prometheus.Collector.
+func (c *ErrorLogCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.messages.Describe(ch)
+}
+
+// Collect implements Prometheus.Collector.
+func (c *ErrorLogCollector) Collect(ch chan<- prometheus.Metric) {
+	c.messages.Collect(ch)
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+)
+
+func TestErrorLogCollector(t *testing.T) {
+	c := NewErrorLogCollector(promslog.NewNopLogger(), &Config{ErrorLogCodes: []string{"AH00484", "AH01102"}})
+	for _, line := range []string{
+		`[Mon Oct 19 10:15:02.123456 2026] [mpm_event:error] [pid 4211:tid 140170003531648] AH00484: server reached MaxRequestWorkers setting, consider raising the MaxRequestWorkers setting`,
+		`[Mon Oct 19 10:16:02.123456 2026] [mpm_event:error] [pid 4211:tid 140170003531648] AH00484: server reached MaxRequestWorkers setting, consider raising the MaxRequestWorkers setting`,
+		`[Mon Oct 19 10:15:07.000001 2026] [proxy_http:error] [pid 4212:tid 140170003531649] (70007)The timeout specified has expired: [client 192.0.2.1:51234] AH01102: error reading status line from remote server app-01:8080, referer: https://www.example.com/`,
+		`[Mon Oct 19 10:15:09.000001 2026] [authz_core:error] [pid 4212:tid 140170003531650] [client 192.0.2.9:40000] AH01630: client denied by server configuration: /srv/www/private`,
+		`[Mon Oct 19 10:15:10.000001 2026] [ssl:warn] [pid 4210:tid 140170003531651] AH01909: www.example.com:443:0 server certificate does NOT include an ID which matches the server name`,
+		`[Mon Oct 19 10:15:11.000001 2026] [php:notice] [pid 4213:tid 140170003531652] [client 192.0.2.3:40001] PHP Notice: Undefined index: q in /srv/www/index.php on line 3`,
+		`[Mon Oct 19 10:15:12 2026] [error] [client 192.0.2.4] File does not exist: /srv/www/favicon.ico`,
+		`PHP Warning:  something printed by a CGI script`,
+	} {
+		c.handleLine(line)
+	}
+
+	expected := `
+# HELP apache_error_log_messages_total Messages written to the error log by module, level and message code
+# TYPE apache_error_log_messages_total counter
+apache_error_log_messages_total{code="",level="error",module=""} 1
+apache_error_log_messages_total{code="",level="notice",module="php"} 1
+apache_error_log_messages_total{code="AH00484",level="error",module="mpm_event"} 2
+apache_error_log_messages_total{code="AH01102",level="error",module="proxy_http"} 1
+apache_error_log_messages_total{code="other",level="error",module="authz_core"} 1
+apache_error_log_messages_total{code="other",level="warn",module="ssl"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestErrorLogCollectorTailsFiles(t *testing.T) {
+	const maxRequestWorkers = `[Mon Oct 19 10:15:02.123456 2026] [mpm_event:error] [pid 4211:tid 140170003531648] AH00484: server reached MaxRequestWorkers setting, consider raising the MaxRequestWorkers setting` + "\n"
+
+	path := filepath.Join(t.TempDir(), "error_log")
+	appendFile(t, path, maxRequestWorkers)
+	c := NewErrorLogCollector(promslog.NewNopLogger(), &Config{ErrorLogFiles: []string{path}, ErrorLogCodes: []string{"AH00484"}})
+	defer c.Close()
+
+	// Lines written before the tailer opens the file are skipped, so a
+	// notice is written until it has been counted.
+	waitFor := func(counted func() bool, write func()) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !counted() {
+			if time.Now().After(deadline) {
+				t.Fatal("timed out waiting for the error log to be tailed")
+			}
+			write()
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+	notices := c.messages.WithLabelValues("core", "notice", "other")
+	waitFor(func() bool { return testutil.ToFloat64(notices) > 0 }, func() {
+		appendFile(t, path, "[Mon Oct 19 10:15:03.000001 2026] [core:notice] [pid 4210:tid 140170003531648] AH00094: Command line: '/usr/sbin/httpd -D FOREGROUND'\n")
+	})
+
+	appendFile(t, path, maxRequestWorkers+maxRequestWorkers)
+	reached := c.messages.WithLabelValues("mpm_event", "error", "AH00484")
+	waitFor(func() bool { return testutil.ToFloat64(reached) >= 2 }, func() {})
+	if v := testutil.ToFloat64(reached); v != 2 {
+		t.Errorf("expected 2 AH00484 messages, got %v", v)
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// heartbeatExpiry is how long a server is kept after its last heartbeat.
+// mod_heartbeat sends one every second.
+const heartbeatExpiry = 10 * time.Second
+
+// heartbeat is the last worker counts announced by a server.
+type heartbeat struct {
+	ready    float64
+	busy     float64
+	lastSeen time.Time
+}
+
+// parseHeartbeatFields reads the ready and busy counts of a mod_heartbeat
+// message or HeartbeatStorage line, e.g. "v=1&ready=75&busy=2".
+func parseHeartbeatFields(values url.Values) (ready, busy float64, err error) {
+	if ready, err = strconv.ParseFloat(values.Get("ready"), 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid ready: %w", err)
+	}
+	if busy, err = strconv.ParseFloat(values.Get("busy"), 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid busy: %w", err)
+	}
+	return ready, busy, nil
+}
+
+// parseHeartbeatMessage reads a datagram sent by mod_heartbeat.
+func parseHeartbeatMessage(msg []byte) (heartbeat, error) {
+	values, err := url.ParseQuery(strings.TrimSpace(string(msg)))
+	if err != nil {
+		return heartbeat{}, err
+	}
+	if v := values.Get("v"); v != "1" {
+		return heartbeat{}, fmt.Errorf("unsupported heartbeat version %q", v)
+	}
+	ready, busy, err := parseHeartbeatFields(values)
+	if err != nil {
+		return heartbeat{}, err
+	}
+	return heartbeat{ready: ready, busy: busy}, nil
+}
+
+// readHeartbeatStorage reads a HeartbeatStorage file written by
+// mod_heartmonitor. Each line is "<ip> &ready=N&busy=M&lastseen=S&port=P",
+// where lastseen is the number of seconds between the last heartbeat and the
+// time the file was written.
+func readHeartbeatStorage(path string) (map[string]heartbeat, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	servers := make(map[string]heartbeat)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		server, query, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("%s: invalid line %q", path, line)
+		}
+		values, err := url.ParseQuery(strings.TrimPrefix(strings.TrimSpace(query), "&"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", path, server, err)
+		}
+		ready, busy, err := parseHeartbeatFields(values)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", path, server, err)
+		}
+		hb := heartbeat{ready: ready, busy: busy, lastSeen: info.ModTime()}
+		if s := values.Get("lastseen"); s != "" {
+			ago, err := strconv.Atoi(s)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: invalid lastseen: %w", path, server, err)
+			}
+			hb.lastSeen = hb.lastSeen.Add(-time.Duration(ago) * time.Second)
+		}
+		servers[server] = hb
+	}
+	return servers, scanner.Err()
+}
+
+// listenHeartbeat opens a UDP socket for address, joining its group if it is
+// a multicast address.
+func listenHeartbeat(address string) (*net.UDPConn, error) {
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	if addr.IP.IsMulticast() {
+		return net.ListenMulticastUDP("udp", nil, addr)
+	}
+	return net.ListenUDP("udp", addr)
+}
+
+// HeartbeatCollector exports the worker counts servers announce with
+// mod_heartbeat, either received on the heartbeat address or read from a
+// mod_heartmonitor HeartbeatStorage file.
+type HeartbeatCollector struct {
+	storageFile string
+	conn        *net.UDPConn
+	mutex       sync.Mutex
+	servers     map[string]heartbeat
+	logger      *slog.Logger
+
+	readyWorkers *prometheus.Desc
+	busyWorkers  *prometheus.Desc
+	lastSeen     *prometheus.Desc
+}
+
+// NewHeartbeatCollector returns a collector reading config.HeartbeatStorageFile
+// on every scrape and, if config.HeartbeatAddress is set, listening for
+// heartbeats on it until Close is called. Servers that stop sending
+// heartbeats are dropped after heartbeatExpiry.
+func NewHeartbeatCollector(logger *slog.Logger, config *Config) (*HeartbeatCollector, error) {
+	c := &HeartbeatCollector{
+		storageFile: config.HeartbeatStorageFile,
+		servers:     make(map[string]heartbeat),
+		logger:      logger,
+		readyWorkers: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "heartbeat", "ready_workers"),
+			"Idle workers last announced by a server with mod_heartbeat",
+			[]string{"server"}, nil,
+		),
+		busyWorkers: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "heartbeat", "busy_workers"),
+			"Busy workers last announced by a server with mod_heartbeat",
+			[]string{"server"}, nil,
+		),
+		lastSeen: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "heartbeat", "last_seen_timestamp_seconds"),
+			"Time of the last heartbeat of a server, in seconds since the epoch",
+			[]string{"server"}, nil,
+		),
+	}
+
+	if config.HeartbeatAddress != "" {
+		conn, err := listenHeartbeat(config.HeartbeatAddress)
+		if err != nil {
+			return nil, fmt.Errorf("error listening for heartbeats: %w", err)
+		}
+		c.conn = conn
+		go c.listen()
+	}
+	return c, nil
+}
+
+// listen records the heartbeats received until the connection is closed.
+func (c *HeartbeatCollector) listen() {
+	buf := make([]byte, 1024)
+	for {
+		n, addr, err := c.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			c.logger.Error("Error receiving heartbeat", "err", err)
+			continue
+		}
+		hb, err := parseHeartbeatMessage(buf[:n])
+		if err != nil {
+			c.logger.Debug("Ignoring invalid heartbeat", "from", addr, "err", err)
+			continue
+		}
+		hb.lastSeen = time.Now()
+
+		c.mutex.Lock()
+		c.servers[addr.IP.String()] = hb
+		c.mutex.Unlock()
+	}
+}
+
+// Close stops listening for heartbeats.
+func (c *HeartbeatCollector) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// Describe implements Prometheus.Collector.
+func (c *HeartbeatCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.readyWorkers
+	ch <- c.busyWorkers
+	ch <- c.lastSeen
+}
+
+// Collect implements Prometheus.Collector.
+func (c *HeartbeatCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	servers := make(map[string]heartbeat, len(c.servers))
+	for server, hb := range c.servers {
+		if time.Since(hb.lastSeen) > heartbeatExpiry {
+			delete(c.servers, server)
+			continue
+		}
+		servers[server] = hb
+	}
+	c.mutex.Unlock()
+
+	if c.storageFile != "" {
+		stored, err := readHeartbeatStorage(c.storageFile)
+		if err != nil {
+			c.logger.Error("Error reading heartbeat storage", "file", c.storageFile, "err", err)
+		}
+		for server, hb := range stored {
+			if received, ok := servers[server]; !ok || hb.lastSeen.After(received.lastSeen) {
+				servers[server] = hb
+			}
+		}
+	}
+
+	for server, hb := range servers {
+		ch <- prometheus.MustNewConstMetric(c.readyWorkers, prometheus.GaugeValue, hb.ready, server)
+		ch <- prometheus.MustNewConstMetric(c.busyWorkers, prometheus.GaugeValue, hb.busy, server)
+		ch <- prometheus.MustNewConstMetric(c.lastSeen, prometheus.GaugeValue, float64(hb.lastSeen.Unix()), server)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+)
+
+func TestHeartbeatListener(t *testing.T) {
+	c, err := NewHeartbeatCollector(promslog.NewNopLogger(), &Config{HeartbeatAddress: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	conn, err := net.DialUDP("udp", nil, c.conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for _, msg := range []string{"not a heartbeat", "v=2&ready=1&busy=1", "v=1&ready=74&busy=1", "v=1&ready=72&busy=3"} {
+		if _, err := conn.Write([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := `
+# HELP apache_heartbeat_busy_workers Busy workers last announced by a server with mod_heartbeat
+# TYPE apache_heartbeat_busy_workers gauge
+apache_heartbeat_busy_workers{server="127.0.0.1"} 3
+# HELP apache_heartbeat_ready_workers Idle workers last announced by a server with mod_heartbeat
+# TYPE apache_heartbeat_ready_workers gauge
+apache_heartbeat_ready_workers{server="127.0.0.1"} 72
+`
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := testutil.CollectAndCompare(c, strings.NewReader(expected), "apache_heartbeat_ready_workers", "apache_heartbeat_busy_workers")
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if n := testutil.CollectAndCount(c, "apache_heartbeat_last_seen_timestamp_seconds"); n != 1 {
+		t.Errorf("expected 1 last seen series, got %d", n)
+	}
+
+	// A server that stopped sending heartbeats is dropped.
+	c.mutex.Lock()
+	c.servers["127.0.0.2"] = heartbeat{ready: 10, lastSeen: time.Now().Add(-heartbeatExpiry - time.Second)}
+	c.mutex.Unlock()
+	if n := testutil.CollectAndCount(c, "apache_heartbeat_ready_workers"); n != 1 {
+		t.Errorf("expected 1 server after expiry, got %d", n)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.servers["127.0.0.2"]; ok {
+		t.Error("expected the stale server to be removed")
+	}
+}
+
+func TestHeartbeatStorageFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hb.dat")
+	storage := "10.0.0.11 &ready=75&busy=0&lastseen=3&port=80\n10.0.0.12 &ready=10&busy=65&lastseen=120&port=80\n"
+	if err := os.WriteFile(path, []byte(storage), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	written := time.Unix(1792400000, 0)
+	if err := os.Chtimes(path, written, written); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewHeartbeatCollector(promslog.NewNopLogger(), &Config{HeartbeatStorageFile: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `
+# HELP apache_heartbeat_busy_workers Busy workers last announced by a server with mod_heartbeat
+# TYPE apache_heartbeat_busy_workers gauge
+apache_heartbeat_busy_workers{server="10.0.0.11"} 0
+apache_heartbeat_busy_workers{server="10.0.0.12"} 65
+# HELP apache_heartbeat_last_seen_timestamp_seconds Time of the last heartbeat of a server, in seconds since the epoch
+# TYPE apache_heartbeat_last_seen_timestamp_seconds gauge
+apache_heartbeat_last_seen_timestamp_seconds{server="10.0.0.11"} 1.792399997e+09
+apache_heartbeat_last_seen_timestamp_seconds{server="10.0.0.12"} 1.79239988e+09
+# HELP apache_heartbeat_ready_workers Idle workers last announced by a server with mod_heartbeat
+# TYPE apache_heartbeat_ready_workers gauge
+apache_heartbeat_ready_workers{server="10.0.0.11"} 75
+apache_heartbeat_ready_workers{server="10.0.0.12"} 10
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
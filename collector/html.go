@@ -0,0 +1,133 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// htmlTable is a table read from one of Apache's HTML status pages.
+type htmlTable struct {
+	// before is the text between the previous table and this one, which
+	// is where Apache puts the heading describing a table.
+	before string
+	header []string
+	rows   [][]string
+}
+
+// column returns the cell of row under the header name, or "" if there is no
+// such column.
+func (t *htmlTable) column(row []string, name string) string {
+	for i, h := range t.header {
+		if strings.EqualFold(h, name) && i < len(row) {
+			return row[i]
+		}
+	}
+	return ""
+}
+
+// hasColumn reports whether the table has a column with the header name.
+func (t *htmlTable) hasColumn(name string) bool {
+	for _, h := range t.header {
+		if strings.EqualFold(h, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseHTMLTables returns the tables of an HTML page in document order. Rows
+// made of <th> cells become the header, all other rows are data. Cell text is
+// whitespace-trimmed.
+func parseHTMLTables(data []byte) ([]htmlTable, error) {
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		tables []htmlTable
+		before strings.Builder
+	)
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			before.WriteString(n.Data)
+			return
+		case n.Type == html.ElementNode && n.DataAtom == atom.Table:
+			t := htmlTable{before: strings.Join(strings.Fields(before.String()), " ")}
+			before.Reset()
+			readTableRows(n, &t)
+			tables = append(tables, t)
+			return
+		case n.Type == html.ElementNode && (n.DataAtom == atom.Br || n.DataAtom == atom.Hr):
+			before.WriteString("\n")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	return tables, nil
+}
+
+// readTableRows fills t with the rows below n, not descending into nested
+// tables.
+func readTableRows(n *html.Node, t *htmlTable) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		switch c.DataAtom {
+		case atom.Table:
+			continue
+		case atom.Tr:
+			var (
+				cells    []string
+				isHeader = true
+			)
+			for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+				if cell.Type != html.ElementNode || (cell.DataAtom != atom.Td && cell.DataAtom != atom.Th) {
+					continue
+				}
+				if cell.DataAtom == atom.Td {
+					isHeader = false
+				}
+				cells = append(cells, strings.Join(strings.Fields(nodeText(cell)), " "))
+			}
+			if len(cells) == 0 {
+				continue
+			}
+			if isHeader && t.header == nil {
+				t.header = cells
+			} else {
+				t.rows = append(t.rows, cells)
+			}
+		default:
+			readTableRows(c, t)
+		}
+	}
+}
+
+// nodeText returns the concatenated text below n.
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(nodeText(c))
+	}
+	return sb.String()
+}
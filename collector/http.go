@@ -0,0 +1,86 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/prometheus/common/version"
+)
+
+// httpClient requests pages from Apache with the target settings shared by
+// every collector that talks to it: TLS verification, Host override, custom
+// headers and User-Agent.
+type httpClient struct {
+	client        *http.Client
+	hostOverride  string
+	customHeaders map[string]string
+	userAgent     string
+}
+
+func newHTTPClient(config *Config) *httpClient {
+	return &httpClient{
+		client: &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: config.Insecure},
+			},
+		},
+		hostOverride:  config.HostOverride,
+		customHeaders: config.CustomHeaders,
+		userAgent:     fmt.Sprintf("Prometheus-Apache-Exporter/%s", version.Version),
+	}
+}
+
+// newRequest builds a GET request for uri.
+func (c *httpClient) newRequest(uri string) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error building scraping request: %w", err)
+	}
+
+	if c.hostOverride != "" {
+		req.Host = c.hostOverride
+	}
+
+	for k, v := range c.customHeaders {
+		req.Header.Add(k, v)
+	}
+
+	req.Header.Set("User-Agent", c.userAgent)
+	return req, nil
+}
+
+// get fetches uri, returning an error unless Apache answers 200 OK.
+func (c *httpClient) get(uri string) ([]byte, error) {
+	req, err := c.newRequest(uri)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error scraping Apache: %w", err)
+	}
+	return readResponse(resp)
+}
+
+// readResponse reads and closes the body of resp, returning an error unless
+// the status is 200 OK.
+func readResponse(resp *http.Response) ([]byte, error) {
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		if err != nil {
+			data = []byte(err.Error())
+		}
+		return nil, fmt.Errorf("status %s (%d): %s", resp.Status, resp.StatusCode, data)
+	}
+	return data, nil
+}
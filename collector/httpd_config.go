@@ -0,0 +1,302 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Apache refuses to nest includes deeper than this.
+const maxIncludeDepth = 128
+
+// Matches ${NAME} references to Define'd or environment variables.
+var reConfigVariable = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// configDirective is a single directive read from an httpd config file.
+type configDirective struct {
+	name string
+	args []string
+	file string
+	line int
+	// ifModules holds the arguments of the <IfModule> sections enclosing
+	// the directive, outermost first.
+	ifModules []string
+	// vhost is the enclosing <VirtualHost> section, if any.
+	vhost *configVirtualHost
+}
+
+// configVirtualHost is a <VirtualHost> section.
+type configVirtualHost struct {
+	addrs []string
+}
+
+// configParser reads an httpd config file and the files it includes.
+type configParser struct {
+	serverRoot string
+	defines    map[string]string
+	directives []configDirective
+}
+
+// parseHTTPDConfig reads the directives of an httpd config file, following
+// Include and IncludeOptional and expanding Define'd variables. Directives in
+// <IfDefine> sections that do not apply are skipped; <IfModule> sections are
+// recorded on each directive, as the set of loaded modules is not known.
+func parseHTTPDConfig(path string) ([]configDirective, error) {
+	p := &configParser{
+		serverRoot: filepath.Dir(path),
+		defines:    make(map[string]string),
+	}
+	if err := p.parseFile(path, 0, nil, nil); err != nil {
+		return nil, err
+	}
+	return p.directives, nil
+}
+
+// parseFile reads path, which was included from within the given <IfModule>
+// and <VirtualHost> sections.
+func (p *configParser) parseFile(path string, depth int, ifModules []string, vhost *configVirtualHost) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("%s: includes nested deeper than %d levels", path, maxIncludeDepth)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var (
+		sections []string
+		// skip counts the sections entered since a false <IfDefine>.
+		skip      int
+		continued string
+		lineNo    int
+	)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasSuffix(line, "\\") {
+			continued += strings.TrimSuffix(line, "\\") + " "
+			continue
+		}
+		line = strings.TrimSpace(continued + line)
+		continued = ""
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = p.expand(line)
+
+		if strings.HasPrefix(line, "</") {
+			name := strings.TrimSuffix(strings.TrimPrefix(line, "</"), ">")
+			if len(sections) == 0 || !strings.EqualFold(sections[len(sections)-1], name) {
+				return fmt.Errorf("%s:%d: unexpected </%s>", path, lineNo, name)
+			}
+			sections = sections[:len(sections)-1]
+			if skip > 0 {
+				skip--
+				continue
+			}
+			switch strings.ToLower(name) {
+			case "ifmodule":
+				ifModules = ifModules[:len(ifModules)-1]
+			case "virtualhost":
+				vhost = nil
+			}
+			continue
+		}
+
+		if strings.HasPrefix(line, "<") {
+			if !strings.HasSuffix(line, ">") {
+				return fmt.Errorf("%s:%d: unterminated section %q", path, lineNo, line)
+			}
+			fields := splitConfigArgs(strings.TrimSuffix(strings.TrimPrefix(line, "<"), ">"))
+			if len(fields) == 0 {
+				return fmt.Errorf("%s:%d: empty section", path, lineNo)
+			}
+			sections = append(sections, fields[0])
+			if skip > 0 {
+				skip++
+				continue
+			}
+			switch strings.ToLower(fields[0]) {
+			case "ifdefine":
+				if len(fields) != 2 {
+					return fmt.Errorf("%s:%d: <IfDefine> takes one argument", path, lineNo)
+				}
+				if !p.defined(fields[1]) {
+					skip = 1
+				}
+			case "ifmodule":
+				ifModules = append(ifModules, strings.Join(fields[1:], " "))
+			case "virtualhost":
+				vhost = &configVirtualHost{addrs: fields[1:]}
+			}
+			continue
+		}
+
+		if skip > 0 {
+			continue
+		}
+
+		fields := splitConfigArgs(line)
+		d := configDirective{
+			name:      fields[0],
+			args:      fields[1:],
+			file:      path,
+			line:      lineNo,
+			ifModules: append([]string(nil), ifModules...),
+			vhost:     vhost,
+		}
+
+		switch strings.ToLower(d.name) {
+		case "define":
+			if len(d.args) == 0 {
+				return fmt.Errorf("%s:%d: Define takes one or two arguments", path, lineNo)
+			}
+			p.defines[d.args[0]] = strings.Join(d.args[1:], " ")
+		case "undefine":
+			if len(d.args) == 1 {
+				delete(p.defines, d.args[0])
+			}
+		case "serverroot":
+			if len(d.args) == 1 {
+				p.serverRoot = d.args[0]
+			}
+		case "include", "includeoptional":
+			if len(d.args) != 1 {
+				return fmt.Errorf("%s:%d: %s takes one argument", path, lineNo, d.name)
+			}
+			if err := p.include(d.args[0], strings.EqualFold(d.name, "includeoptional"), depth, d.ifModules, vhost); err != nil {
+				return fmt.Errorf("%s:%d: %w", path, lineNo, err)
+			}
+			continue
+		}
+
+		p.directives = append(p.directives, d)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(sections) > 0 {
+		return fmt.Errorf("%s: missing </%s>", path, sections[len(sections)-1])
+	}
+
+	return nil
+}
+
+// include parses the files matched by an Include or IncludeOptional pattern.
+// Relative patterns are resolved against ServerRoot, and directories are
+// read recursively in alphabetical order.
+func (p *configParser) include(pattern string, optional bool, depth int, ifModules []string, vhost *configVirtualHost) error {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(p.serverRoot, pattern)
+	}
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		if optional {
+			return nil
+		}
+		return fmt.Errorf("no files matching Include %s", pattern)
+	}
+
+	sort.Strings(matches)
+	for _, match := range matches {
+		var files []string
+		err := filepath.WalkDir(match, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			if optional {
+				continue
+			}
+			return err
+		}
+		for _, file := range files {
+			if err := p.parseFile(file, depth+1, ifModules, vhost); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// expand replaces ${NAME} with the value of a Define, or failing that an
+// environment variable, as httpd does when reading its config.
+func (p *configParser) expand(line string) string {
+	return reConfigVariable.ReplaceAllStringFunc(line, func(ref string) string {
+		name := ref[2 : len(ref)-1]
+		if val, ok := p.defines[name]; ok {
+			return val
+		}
+		if val, ok := os.LookupEnv(name); ok {
+			return val
+		}
+		return ref
+	})
+}
+
+// defined evaluates an <IfDefine> argument.
+func (p *configParser) defined(arg string) bool {
+	if name, negated := strings.CutPrefix(arg, "!"); negated {
+		_, ok := p.defines[name]
+		return !ok
+	}
+	_, ok := p.defines[arg]
+	return ok
+}
+
+// splitConfigArgs splits a config line into whitespace separated words,
+// keeping double or single quoted strings together.
+func splitConfigArgs(line string) []string {
+	var (
+		args  []string
+		word  strings.Builder
+		quote rune
+		inArg bool
+	)
+	for _, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, word.String())
+				word.Reset()
+				inArg = false
+			}
+		default:
+			word.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, word.String())
+	}
+	return args
+}
@@ -0,0 +1,340 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// jkWorker is a worker read from the jkstatus properties output, with its
+// attributes keyed by name.
+type jkWorker struct {
+	name  string
+	attrs map[string]string
+}
+
+// jkStatus is the worker tree shown by the mod_jk status worker.
+type jkStatus struct {
+	// balancers are the load balancer workers with their members.
+	balancers []jkBalancer
+	// workers are the AJP workers that are not members of a balancer.
+	workers []jkWorker
+}
+
+type jkBalancer struct {
+	jkWorker
+	members []jkWorker
+}
+
+// jkWorkerList appends the comma-separated worker names in value to list,
+// skipping those already in it.
+func jkWorkerList(list []string, value string) []string {
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" && !slices.Contains(list, name) {
+			list = append(list, name)
+		}
+	}
+	return list
+}
+
+// parseJKStatus reads the output of the jkstatus worker requested with
+// ?mime=prop. Every line is worker.<name>.<attribute>=<value>, with the top
+// level workers listed in worker.list and the members of a load balancer in
+// worker.<lb>.balance_workers. Both lists may be split over several lines,
+// as in workers.properties.
+func parseJKStatus(data []byte) (*jkStatus, error) {
+	var list []string
+	attrs := make(map[string]map[string]string)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || !strings.HasPrefix(key, "worker.") {
+			return nil, fmt.Errorf("invalid jkstatus line %q", line)
+		}
+		key = strings.TrimPrefix(key, "worker.")
+		if key == "list" {
+			list = jkWorkerList(list, value)
+			continue
+		}
+		i := strings.LastIndexByte(key, '.')
+		if i < 0 {
+			continue
+		}
+		name, attr := key[:i], key[i+1:]
+		if attrs[name] == nil {
+			attrs[name] = make(map[string]string)
+		}
+		if attr == "balance_workers" {
+			value = strings.Join(jkWorkerList(jkWorkerList(nil, attrs[name][attr]), value), ",")
+		}
+		attrs[name][attr] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if list == nil {
+		return nil, fmt.Errorf("no worker.list in jkstatus output")
+	}
+
+	status := &jkStatus{}
+	for _, name := range list {
+		w := jkWorker{name: name, attrs: attrs[name]}
+		switch w.attrs["type"] {
+		case "lb":
+			b := jkBalancer{jkWorker: w}
+			for _, member := range jkWorkerList(nil, w.attrs["balance_workers"]) {
+				b.members = append(b.members, jkWorker{name: member, attrs: attrs[member]})
+			}
+			status.balancers = append(status.balancers, b)
+		case "status", "":
+			// The status worker itself has nothing to export.
+		default:
+			status.workers = append(status.workers, w)
+		}
+	}
+	return status, nil
+}
+
+// JKStatusCollector scrapes the mod_jk status worker for the state of the
+// load balancers and AJP workers fronting Tomcat.
+type JKStatusCollector struct {
+	URI    string
+	mutex  sync.Mutex
+	http   *httpClient
+	logger *slog.Logger
+
+	up                *prometheus.Desc
+	balancerMembers   *prometheus.Desc
+	balancerBusy      *prometheus.Desc
+	balancerMaxBusy   *prometheus.Desc
+	memberState       *prometheus.Desc
+	memberActivation  *prometheus.Desc
+	memberBusy        *prometheus.Desc
+	memberMaxBusy     *prometheus.Desc
+	memberConnected   *prometheus.Desc
+	memberElected     *prometheus.Desc
+	memberErrors      *prometheus.Desc
+	memberClientErrs  *prometheus.Desc
+	memberReplyTOs    *prometheus.Desc
+	memberReadBytes   *prometheus.Desc
+	memberTransferred *prometheus.Desc
+}
+
+func NewJKStatusCollector(logger *slog.Logger, config *Config) *JKStatusCollector {
+	memberLabels := []string{"balancer", "member"}
+	return &JKStatusCollector{
+		URI:    config.JKStatusURI,
+		http:   newHTTPClient(config),
+		logger: logger,
+		up: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "jk_status", "up"),
+			"Could the mod_jk status worker be reached",
+			nil,
+			nil),
+		balancerMembers: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "jk_balancer", "members"),
+			"mod_jk load balancer members by state (good, degraded, bad)",
+			[]string{"balancer", "state"}, nil,
+		),
+		balancerBusy: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "jk_balancer", "busy"),
+			"mod_jk load balancer requests currently being processed",
+			[]string{"balancer"}, nil,
+		),
+		balancerMaxBusy: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "jk_balancer", "max_busy"),
+			"mod_jk load balancer maximum concurrent requests since the last reset",
+			[]string{"balancer"}, nil,
+		),
+		memberState: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "jk_member", "state"),
+			"mod_jk worker state, e.g. OK, OK/IDLE, ERR or ERR/REC",
+			[]string{"balancer", "member", "state"}, nil,
+		),
+		memberActivation: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "jk_member", "activation"),
+			"mod_jk balancer member activation (ACT, DIS or STP)",
+			[]string{"balancer", "member", "activation"}, nil,
+		),
+		memberBusy: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "jk_member", "busy"),
+			"mod_jk worker requests currently being processed",
+			memberLabels, nil,
+		),
+		memberMaxBusy: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "jk_member", "max_busy"),
+			"mod_jk worker maximum concurrent requests since the last reset",
+			memberLabels, nil,
+		),
+		memberConnected: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "jk_member", "connected"),
+			"mod_jk worker connected backend endpoints",
+			memberLabels, nil,
+		),
+		memberElected: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "jk_member", "elected_total"),
+			"mod_jk balancer member times elected to serve a request",
+			memberLabels, nil,
+		),
+		memberErrors: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "jk_member", "errors_total"),
+			"mod_jk worker backend errors",
+			memberLabels, nil,
+		),
+		memberClientErrs: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "jk_member", "client_errors_total"),
+			"mod_jk worker client errors",
+			memberLabels, nil,
+		),
+		memberReplyTOs: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "jk_member", "reply_timeouts_total"),
+			"mod_jk worker reply timeouts",
+			memberLabels, nil,
+		),
+		memberReadBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "jk_member", "read_bytes_total"),
+			"mod_jk worker bytes read from the backend",
+			memberLabels, nil,
+		),
+		memberTransferred: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "jk_member", "transferred_bytes_total"),
+			"mod_jk worker bytes transferred to the backend",
+			memberLabels, nil,
+		),
+	}
+}
+
+// Describe implements Prometheus.Collector.
+func (c *JKStatusCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up
+	ch <- c.balancerMembers
+	ch <- c.balancerBusy
+	ch <- c.balancerMaxBusy
+	ch <- c.memberState
+	ch <- c.memberActivation
+	ch <- c.memberBusy
+	ch <- c.memberMaxBusy
+	ch <- c.memberConnected
+	ch <- c.memberElected
+	ch <- c.memberErrors
+	ch <- c.memberClientErrs
+	ch <- c.memberReplyTOs
+	ch <- c.memberReadBytes
+	ch <- c.memberTransferred
+}
+
+// collectValue sends the numeric attribute attr of w, if present.
+func collectValue(ch chan<- prometheus.Metric, desc *prometheus.Desc, valueType prometheus.ValueType, w jkWorker, attr string, labels ...string) error {
+	v, ok := w.attrs[attr]
+	if !ok {
+		return nil
+	}
+	val, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return fmt.Errorf("%s: invalid %s: %w", w.name, attr, err)
+	}
+	ch <- prometheus.MustNewConstMetric(desc, valueType, val, labels...)
+	return nil
+}
+
+func (c *JKStatusCollector) collectMember(ch chan<- prometheus.Metric, balancer string, w jkWorker) error {
+	if state := w.attrs["state"]; state != "" {
+		ch <- prometheus.MustNewConstMetric(c.memberState, prometheus.GaugeValue, 1, balancer, w.name, state)
+	}
+	if activation := w.attrs["activation"]; activation != "" {
+		ch <- prometheus.MustNewConstMetric(c.memberActivation, prometheus.GaugeValue, 1, balancer, w.name, activation)
+	}
+
+	for _, m := range []struct {
+		desc      *prometheus.Desc
+		valueType prometheus.ValueType
+		attr      string
+	}{
+		{c.memberBusy, prometheus.GaugeValue, "busy"},
+		{c.memberMaxBusy, prometheus.GaugeValue, "max_busy"},
+		{c.memberConnected, prometheus.GaugeValue, "connected"},
+		{c.memberElected, prometheus.CounterValue, "elected"},
+		{c.memberErrors, prometheus.CounterValue, "errors"},
+		{c.memberClientErrs, prometheus.CounterValue, "client_errors"},
+		{c.memberReplyTOs, prometheus.CounterValue, "reply_timeouts"},
+		{c.memberReadBytes, prometheus.CounterValue, "read"},
+		{c.memberTransferred, prometheus.CounterValue, "transferred"},
+	} {
+		if err := collectValue(ch, m.desc, m.valueType, w, m.attr, balancer, w.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *JKStatusCollector) collect(ch chan<- prometheus.Metric) error {
+	data, err := c.http.get(c.URI)
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+		return err
+	}
+	status, err := parseJKStatus(data)
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 1)
+
+	for _, b := range status.balancers {
+		for _, state := range []string{"good", "degraded", "bad"} {
+			if err := collectValue(ch, c.balancerMembers, prometheus.GaugeValue, b.jkWorker, state, b.name, state); err != nil {
+				return err
+			}
+		}
+		if err := collectValue(ch, c.balancerBusy, prometheus.GaugeValue, b.jkWorker, "busy", b.name); err != nil {
+			return err
+		}
+		if err := collectValue(ch, c.balancerMaxBusy, prometheus.GaugeValue, b.jkWorker, "max_busy", b.name); err != nil {
+			return err
+		}
+		for _, m := range b.members {
+			if err := c.collectMember(ch, b.name, m); err != nil {
+				return err
+			}
+		}
+	}
+
+	// AJP workers used directly, without a load balancer, have an empty
+	// balancer label.
+	for _, w := range status.workers {
+		if err := c.collectMember(ch, "", w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Collect implements Prometheus.Collector.
+func (c *JKStatusCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.collect(ch); err != nil {
+		c.logger.Error("Error scraping mod_jk status worker", "err", err)
+	}
+}
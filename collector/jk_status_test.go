@@ -0,0 +1,126 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+)
+
+func TestJKStatus(t *testing.T) {
+	page, err := os.ReadFile("testdata/jkstatus.properties")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(page)
+	}))
+	defer server.Close()
+
+	c := NewJKStatusCollector(promslog.NewNopLogger(), &Config{JKStatusURI: server.URL + "/jkstatus?mime=prop"})
+
+	expected := `
+# HELP apache_jk_balancer_busy mod_jk load balancer requests currently being processed
+# TYPE apache_jk_balancer_busy gauge
+apache_jk_balancer_busy{balancer="lb"} 3
+# HELP apache_jk_balancer_max_busy mod_jk load balancer maximum concurrent requests since the last reset
+# TYPE apache_jk_balancer_max_busy gauge
+apache_jk_balancer_max_busy{balancer="lb"} 17
+# HELP apache_jk_balancer_members mod_jk load balancer members by state (good, degraded, bad)
+# TYPE apache_jk_balancer_members gauge
+apache_jk_balancer_members{balancer="lb",state="bad"} 1
+apache_jk_balancer_members{balancer="lb",state="degraded"} 0
+apache_jk_balancer_members{balancer="lb",state="good"} 1
+# HELP apache_jk_member_activation mod_jk balancer member activation (ACT, DIS or STP)
+# TYPE apache_jk_member_activation gauge
+apache_jk_member_activation{activation="ACT",balancer="lb",member="tomcat1"} 1
+apache_jk_member_activation{activation="DIS",balancer="lb",member="tomcat2"} 1
+# HELP apache_jk_member_busy mod_jk worker requests currently being processed
+# TYPE apache_jk_member_busy gauge
+apache_jk_member_busy{balancer="",member="legacy"} 1
+apache_jk_member_busy{balancer="lb",member="tomcat1"} 3
+apache_jk_member_busy{balancer="lb",member="tomcat2"} 0
+# HELP apache_jk_member_connected mod_jk worker connected backend endpoints
+# TYPE apache_jk_member_connected gauge
+apache_jk_member_connected{balancer="",member="legacy"} 2
+apache_jk_member_connected{balancer="lb",member="tomcat1"} 8
+apache_jk_member_connected{balancer="lb",member="tomcat2"} 0
+# HELP apache_jk_member_elected_total mod_jk balancer member times elected to serve a request
+# TYPE apache_jk_member_elected_total counter
+apache_jk_member_elected_total{balancer="lb",member="tomcat1"} 48211
+apache_jk_member_elected_total{balancer="lb",member="tomcat2"} 1022
+# HELP apache_jk_member_errors_total mod_jk worker backend errors
+# TYPE apache_jk_member_errors_total counter
+apache_jk_member_errors_total{balancer="lb",member="tomcat1"} 2
+apache_jk_member_errors_total{balancer="lb",member="tomcat2"} 37
+# HELP apache_jk_member_state mod_jk worker state, e.g. OK, OK/IDLE, ERR or ERR/REC
+# TYPE apache_jk_member_state gauge
+apache_jk_member_state{balancer="lb",member="tomcat1",state="OK/IDLE"} 1
+apache_jk_member_state{balancer="lb",member="tomcat2",state="ERR"} 1
+# HELP apache_jk_member_transferred_bytes_total mod_jk worker bytes transferred to the backend
+# TYPE apache_jk_member_transferred_bytes_total counter
+apache_jk_member_transferred_bytes_total{balancer="lb",member="tomcat1"} 9.214732e+06
+apache_jk_member_transferred_bytes_total{balancer="lb",member="tomcat2"} 201344
+# HELP apache_jk_status_up Could the mod_jk status worker be reached
+# TYPE apache_jk_status_up gauge
+apache_jk_status_up 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"apache_jk_status_up", "apache_jk_balancer_members", "apache_jk_balancer_busy", "apache_jk_balancer_max_busy",
+		"apache_jk_member_state", "apache_jk_member_activation", "apache_jk_member_busy", "apache_jk_member_connected",
+		"apache_jk_member_elected_total", "apache_jk_member_errors_total", "apache_jk_member_transferred_bytes_total",
+	); err != nil {
+		t.Error(err)
+	}
+
+	if n := testutil.CollectAndCount(c); n != 1+5+2*(2+9)+3 {
+		t.Errorf("expected %d series, got %d", 1+5+2*(2+9)+3, n)
+	}
+}
+
+func TestJKStatusInvalid(t *testing.T) {
+	for name, page := range map[string]string{
+		"no list":      "worker.lb.type=lb\n",
+		"invalid line": "worker.list=lb\nnot a property\n",
+	} {
+		if _, err := parseJKStatus([]byte(page)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestJKStatusSplitList(t *testing.T) {
+	page, err := os.ReadFile("testdata/jkstatus-split-list.properties")
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := parseJKStatus(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(status.balancers) != 1 || status.balancers[0].name != "lb" {
+		t.Fatalf("expected balancer lb, got %+v", status.balancers)
+	}
+	var members []string
+	for _, m := range status.balancers[0].members {
+		members = append(members, m.name)
+	}
+	if got := strings.Join(members, ","); got != "tomcat1,tomcat2,tomcat3" {
+		t.Errorf("expected members tomcat1,tomcat2,tomcat3, got %s", got)
+	}
+	if len(status.workers) != 1 || status.workers[0].name != "legacy" {
+		t.Errorf("expected worker legacy, got %+v", status.workers)
+	}
+}
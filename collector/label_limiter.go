@@ -0,0 +1,37 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import "sync"
+
+// labelLimiter bounds the values of a label read from logs by admitting the
+// first max distinct values and replacing later ones with "other".
+type labelLimiter struct {
+	max   int
+	mutex sync.Mutex
+	seen  map[string]bool
+}
+
+// newLabelLimiter returns a limiter admitting max values, 0 for no limit.
+func newLabelLimiter(max int) *labelLimiter {
+	return &labelLimiter{max: max, seen: make(map[string]bool)}
+}
+
+// value returns v if it is admitted, "other" otherwise.
+func (l *labelLimiter) value(v string) string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.seen[v] {
+		return v
+	}
+	if l.max > 0 && len(l.seen) >= l.max {
+		return "other"
+	}
+	l.seen[v] = true
+	return v
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Matches the module names and source files <IfModule> uses for MPMs, e.g.
+// mpm_event_module, mod_mpm_event.c or prefork.c.
+var reMPMModule = regexp.MustCompile(`^(?:(?:mod_)?mpm_(\w+?)(?:_module|\.c)|(prefork|worker|event)\.c)$`)
+
+// Limits holds the MPM capacity directives live worker and connection counts
+// are compared against. Zero means unknown.
+type Limits struct {
+	MaxRequestWorkers        float64
+	ServerLimit              float64
+	ThreadsPerChild          float64
+	AsyncRequestWorkerFactor float64
+}
+
+// merge returns l with the non-zero fields of override applied.
+func (l Limits) merge(override Limits) Limits {
+	if override.MaxRequestWorkers != 0 {
+		l.MaxRequestWorkers = override.MaxRequestWorkers
+	}
+	if override.ServerLimit != 0 {
+		l.ServerLimit = override.ServerLimit
+	}
+	if override.ThreadsPerChild != 0 {
+		l.ThreadsPerChild = override.ThreadsPerChild
+	}
+	if override.AsyncRequestWorkerFactor != 0 {
+		l.AsyncRequestWorkerFactor = override.AsyncRequestWorkerFactor
+	}
+	return l
+}
+
+// maxRequestWorkers returns MaxRequestWorkers, falling back to the capacity
+// implied by ServerLimit and ThreadsPerChild.
+func (l Limits) maxRequestWorkers() float64 {
+	if l.MaxRequestWorkers != 0 {
+		return l.MaxRequestWorkers
+	}
+	return l.ServerLimit * l.ThreadsPerChild
+}
+
+// directives returns the known limits keyed by directive name.
+func (l Limits) directives() map[string]float64 {
+	d := make(map[string]float64)
+	if l.MaxRequestWorkers != 0 {
+		d["MaxRequestWorkers"] = l.MaxRequestWorkers
+	}
+	if l.ServerLimit != 0 {
+		d["ServerLimit"] = l.ServerLimit
+	}
+	if l.ThreadsPerChild != 0 {
+		d["ThreadsPerChild"] = l.ThreadsPerChild
+	}
+	if l.AsyncRequestWorkerFactor != 0 {
+		d["AsyncRequestWorkerFactor"] = l.AsyncRequestWorkerFactor
+	}
+	return d
+}
+
+// appliesToMPM reports whether a directive enclosed by the given <IfModule>
+// arguments is in effect for mpm. Sections for unrelated modules are assumed
+// to be active.
+func appliesToMPM(ifModules []string, mpm string) bool {
+	for _, arg := range ifModules {
+		negated := strings.HasPrefix(arg, "!")
+		m := reMPMModule.FindStringSubmatch(strings.TrimPrefix(arg, "!"))
+		if m == nil || mpm == "" || mpm == "UNKNOWN" {
+			continue
+		}
+		name := m[1] + m[2]
+		if (name == mpm) == negated {
+			return false
+		}
+	}
+	return true
+}
+
+// mpmLimits returns the MPM limits set by directives, skipping those in
+// <IfModule> sections for MPMs other than mpm.
+func mpmLimits(directives []configDirective, mpm string) (Limits, error) {
+	var l Limits
+	for _, d := range directives {
+		var field *float64
+		switch strings.ToLower(d.name) {
+		case "maxrequestworkers", "maxclients":
+			field = &l.MaxRequestWorkers
+		case "serverlimit":
+			field = &l.ServerLimit
+		case "threadsperchild":
+			field = &l.ThreadsPerChild
+		case "asyncrequestworkerfactor":
+			field = &l.AsyncRequestWorkerFactor
+		default:
+			continue
+		}
+		if !appliesToMPM(d.ifModules, mpm) {
+			continue
+		}
+		if len(d.args) != 1 {
+			return Limits{}, fmt.Errorf("%s:%d: %s takes one argument", d.file, d.line, d.name)
+		}
+		val, err := strconv.ParseFloat(d.args[0], 64)
+		if err != nil {
+			return Limits{}, fmt.Errorf("%s:%d: invalid %s: %w", d.file, d.line, d.name, err)
+		}
+		*field = val
+	}
+
+	return l, nil
+}
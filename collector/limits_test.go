@@ -0,0 +1,138 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const mpmConfig = `# Server-pool management (MPM specific)
+ServerLimit 32
+
+<IfModule mpm_prefork_module>
+    MaxRequestWorkers      250
+</IfModule>
+
+<IfModule mpm_event_module>
+    ThreadsPerChild          25
+    MaxRequestWorkers       400
+    AsyncRequestWorkerFactor  "3"
+</IfModule>
+
+<IfModule !mpm_prefork_module>
+    <IfModule mod_status.c>
+        ExtendedStatus On
+    </IfModule>
+</IfModule>
+`
+
+func TestMPMLimits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "httpd-mpm.conf")
+	if err := os.WriteFile(path, []byte(mpmConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	directives, err := parseHTTPDConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		mpm      string
+		expected Limits
+	}{
+		{"event", Limits{MaxRequestWorkers: 400, ServerLimit: 32, ThreadsPerChild: 25, AsyncRequestWorkerFactor: 3}},
+		{"prefork", Limits{MaxRequestWorkers: 250, ServerLimit: 32}},
+		{"worker", Limits{ServerLimit: 32}},
+	}
+	for _, test := range tests {
+		l, err := mpmLimits(directives, test.mpm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l != test.expected {
+			t.Errorf("%s: expected %+v, got %+v", test.mpm, test.expected, l)
+		}
+	}
+
+	l, _ := mpmLimits(directives, "worker")
+	if got := l.merge(Limits{ThreadsPerChild: 64}).maxRequestWorkers(); got != 2048 {
+		t.Errorf("expected MaxRequestWorkers derived from ServerLimit * ThreadsPerChild to be 2048, got %v", got)
+	}
+}
+
+func TestMPMLimitsFollowsIncludes(t *testing.T) {
+	directives, err := parseHTTPDConfig("testdata/httpd/conf/httpd.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := mpmLimits(directives, "event")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Limits{MaxRequestWorkers: 400, ServerLimit: 16, ThreadsPerChild: 25}
+	if l != expected {
+		t.Errorf("expected %+v, got %+v", expected, l)
+	}
+}
+
+const limitsEventStatus = `ServerVersion: Apache/2.4.62 (Unix)
+ServerMPM: event
+BusyWorkers: 100
+IdleWorkers: 50
+ConnsTotal: 250
+ConnsAsyncWriting: 10
+ConnsAsyncKeepAlive: 130
+ConnsAsyncClosing: 10
+Scoreboard: _W___
+`
+
+func TestExporterLimits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "httpd-mpm.conf")
+	if err := os.WriteFile(path, []byte(mpmConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e := newTestExporter(t, limitsEventStatus, &Config{
+		HTTPDConfigFile: path,
+		Limits:          Limits{MaxRequestWorkers: 500},
+	})
+	// The config is read once, when the exporter is created.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `
+# HELP apache_async_connection_capacity Maximum concurrent connections the event MPM accepts, (AsyncRequestWorkerFactor + 1) * MaxRequestWorkers
+# TYPE apache_async_connection_capacity gauge
+apache_async_connection_capacity 2000
+# HELP apache_async_connection_utilization_ratio Open connections as a fraction of the event MPM connection capacity
+# TYPE apache_async_connection_utilization_ratio gauge
+apache_async_connection_utilization_ratio 0.125
+# HELP apache_config_limit Configured Apache MPM limits
+# TYPE apache_config_limit gauge
+apache_config_limit{directive="AsyncRequestWorkerFactor"} 3
+apache_config_limit{directive="MaxRequestWorkers"} 500
+apache_config_limit{directive="ServerLimit"} 32
+apache_config_limit{directive="ThreadsPerChild"} 25
+# HELP apache_worker_utilization_ratio Busy workers as a fraction of MaxRequestWorkers
+# TYPE apache_worker_utilization_ratio gauge
+apache_worker_utilization_ratio 0.2
+`
+	for i := 0; i < 2; i++ {
+		if err := testutil.CollectAndCompare(e, strings.NewReader(expected),
+			"apache_async_connection_capacity", "apache_async_connection_utilization_ratio",
+			"apache_config_limit", "apache_worker_utilization_ratio"); err != nil {
+			t.Error(err)
+		}
+	}
+}
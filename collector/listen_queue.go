@@ -0,0 +1,281 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// listenSocket is a TCP socket in the LISTEN state.
+type listenSocket struct {
+	address string
+	port    int
+	// queue is the number of connections waiting to be accepted.
+	queue float64
+}
+
+// parseNetstat reads the "TcpExt:" counters of /proc/net/netstat, which
+// alternates lines of names and values per protocol.
+func parseNetstat(r io.Reader) (map[string]float64, error) {
+	counters := make(map[string]float64)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		names := strings.Fields(scanner.Text())
+		if len(names) == 0 {
+			continue
+		}
+		if !scanner.Scan() {
+			return nil, fmt.Errorf("missing values for %s", names[0])
+		}
+		values := strings.Fields(scanner.Text())
+		if len(names) != len(values) || names[0] != values[0] {
+			return nil, fmt.Errorf("mismatched names and values for %s", names[0])
+		}
+		if names[0] != "TcpExt:" {
+			continue
+		}
+		for i := 1; i < len(names); i++ {
+			v, err := strconv.ParseFloat(values[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q for TcpExt %s", values[i], names[i])
+			}
+			counters[names[i]] = v
+		}
+	}
+	return counters, scanner.Err()
+}
+
+// listenPort returns the port of a Listen directive, e.g. "80",
+// "192.0.2.1:8080" or "[::]:443".
+func listenPort(arg string) (int, error) {
+	if i := strings.LastIndexByte(arg, ':'); i >= 0 {
+		arg = arg[i+1:]
+	}
+	port, err := strconv.Atoi(arg)
+	if err != nil || port <= 0 || port > 65535 {
+		return 0, fmt.Errorf("invalid Listen port %q", arg)
+	}
+	return port, nil
+}
+
+// listenQueuePorts returns the ports to export listen sockets of: those
+// configured, else the Listen directives of the httpd config, else the port
+// of the scrape URI.
+func listenQueuePorts(config *Config) (map[int]bool, error) {
+	ports := make(map[int]bool)
+	for _, port := range config.ListenQueuePorts {
+		ports[port] = true
+	}
+	if len(ports) > 0 {
+		return ports, nil
+	}
+
+	if config.HTTPDConfigFile != "" {
+		directives, err := parseHTTPDConfig(config.HTTPDConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range directives {
+			if strings.EqualFold(d.name, "Listen") && len(d.args) > 0 {
+				port, err := listenPort(d.args[0])
+				if err != nil {
+					return nil, fmt.Errorf("%s:%d: %w", d.file, d.line, err)
+				}
+				ports[port] = true
+			}
+		}
+		if len(ports) > 0 {
+			return ports, nil
+		}
+	}
+
+	u, err := url.Parse(config.ScrapeURI)
+	if err != nil {
+		return nil, err
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scrape URI port %q", port)
+	}
+	ports[p] = true
+	return ports, nil
+}
+
+// ListenQueueCollector exports the accept queue of the sockets Apache listens
+// on and the kernel's listen overflow counters, read from procfs.
+type ListenQueueCollector struct {
+	ports      map[int]bool
+	procfsPath string
+	pidFile    string
+	mutex      sync.Mutex
+	logger     *slog.Logger
+
+	up         *prometheus.Desc
+	queue      *prometheus.Desc
+	overflows  *prometheus.Desc
+	drops      *prometheus.Desc
+	synDrops   *prometheus.Desc
+	syncookies *prometheus.Desc
+}
+
+// NewListenQueueCollector returns a collector for the listen sockets on
+// config.ListenQueuePorts, or the ports found in the httpd config or scrape
+// URI. With config.ProcessPidFile set, sockets are read from the network
+// namespace of the httpd parent. Only Linux is supported.
+func NewListenQueueCollector(logger *slog.Logger, config *Config) (*ListenQueueCollector, error) {
+	if err := checkProcfs(config.ProcfsPath); err != nil {
+		return nil, err
+	}
+	ports, err := listenQueuePorts(config)
+	if err != nil {
+		return nil, fmt.Errorf("error finding listen ports: %w", err)
+	}
+	return &ListenQueueCollector{
+		ports:      ports,
+		procfsPath: config.ProcfsPath,
+		pidFile:    config.ProcessPidFile,
+		logger:     logger,
+		up: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "listen_queue", "up"),
+			"Could the listen sockets be read from procfs",
+			nil,
+			nil),
+		queue: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "listen_queue", "length"),
+			"Connections waiting in the accept queue of a listen socket",
+			[]string{"address"}, nil,
+		),
+		overflows: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "listen_queue", "overflows_total"),
+			"Times the accept queue of a listen socket was full, for all sockets of the host (TcpExt ListenOverflows)",
+			nil,
+			nil),
+		drops: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "listen_queue", "drops_total"),
+			"Connection requests dropped by listen sockets, for all sockets of the host (TcpExt ListenDrops)",
+			nil,
+			nil),
+		synDrops: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "listen_queue", "syn_drops_total"),
+			"SYNs dropped because the SYN queue was full, for all sockets of the host (TcpExt TCPReqQFullDrop)",
+			nil,
+			nil),
+		syncookies: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "listen_queue", "syncookies_sent_total"),
+			"SYN cookies sent because the SYN queue was full, for all sockets of the host (TcpExt SyncookiesSent)",
+			nil,
+			nil),
+	}, nil
+}
+
+// Describe implements Prometheus.Collector.
+func (c *ListenQueueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up
+	ch <- c.queue
+	ch <- c.overflows
+	ch <- c.drops
+	ch <- c.synDrops
+	ch <- c.syncookies
+}
+
+// netDir returns the procfs directory with the network statistics of httpd:
+// those of the parent's network namespace if its PID is known.
+func (c *ListenQueueCollector) netDir() (string, error) {
+	if c.pidFile == "" {
+		return c.procfsPath, nil
+	}
+	pid, err := readPidFile(c.pidFile)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(c.procfsPath, strconv.Itoa(pid)), nil
+}
+
+func (c *ListenQueueCollector) collect(ch chan<- prometheus.Metric) error {
+	dir, err := c.netDir()
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+		return err
+	}
+	sockets, err := readListenSockets(dir)
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+		return err
+	}
+	f, err := os.Open(filepath.Join(dir, "net", "netstat"))
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+		return err
+	}
+	defer f.Close()
+	counters, err := parseNetstat(f)
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 1)
+
+	// With SO_REUSEPORT, there is a socket per listener bucket on the same
+	// address.
+	queues := make(map[string]*listenSocket)
+	for _, s := range sockets {
+		if !c.ports[s.port] {
+			continue
+		}
+		if q, ok := queues[s.address]; ok {
+			q.queue += s.queue
+			continue
+		}
+		queues[s.address] = &s
+	}
+	for address, s := range queues {
+		ch <- prometheus.MustNewConstMetric(c.queue, prometheus.GaugeValue, s.queue, address)
+	}
+
+	for _, counter := range []struct {
+		desc *prometheus.Desc
+		name string
+	}{
+		{c.overflows, "ListenOverflows"},
+		{c.drops, "ListenDrops"},
+		{c.synDrops, "TCPReqQFullDrop"},
+		{c.syncookies, "SyncookiesSent"},
+	} {
+		if v, ok := counters[counter.name]; ok {
+			ch <- prometheus.MustNewConstMetric(counter.desc, prometheus.CounterValue, v)
+		}
+	}
+	return nil
+}
+
+// Collect implements Prometheus.Collector.
+func (c *ListenQueueCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.collect(ch); err != nil {
+		c.logger.Error("Error reading listen sockets", "err", err)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+//go:build linux
+
+package collector
+
+import (
+	"errors"
+	"io/fs"
+	"net"
+	"strconv"
+
+	"github.com/prometheus/procfs"
+)
+
+// tcpListen is the LISTEN state in /proc/net/tcp.
+const tcpListen = 0x0a
+
+// readListenSockets reads the listen sockets of /proc/net/tcp and tcp6 under
+// procfsPath. For listen sockets, the kernel reports the accept queue as
+// rx_queue. Their tx_queue is always 0: the backlog is only available from
+// sock_diag.
+func readListenSockets(procfsPath string) ([]listenSocket, error) {
+	procFS, err := procfs.NewFS(procfsPath)
+	if err != nil {
+		return nil, err
+	}
+	var sockets []listenSocket
+	for _, read := range []func() (procfs.NetTCP, error){procFS.NetTCP, procFS.NetTCP6} {
+		lines, err := read()
+		if errors.Is(err, fs.ErrNotExist) {
+			// IPv6 may be disabled.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range lines {
+			if l.St != tcpListen {
+				continue
+			}
+			sockets = append(sockets, listenSocket{
+				address: net.JoinHostPort(l.LocalAddr.String(), strconv.FormatUint(l.LocalPort, 10)),
+				port:    int(l.LocalPort),
+				queue:   float64(l.RxQueue),
+			})
+		}
+	}
+	return sockets, nil
+}
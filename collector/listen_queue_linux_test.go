@@ -0,0 +1,79 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+//go:build linux
+
+package collector
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+)
+
+func TestListenQueueCollector(t *testing.T) {
+	c, err := NewListenQueueCollector(promslog.NewNopLogger(), &Config{
+		ListenQueuePorts: []int{80, 443},
+		ProcfsPath:       "testdata/proc",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `
+# HELP apache_listen_queue_drops_total Connection requests dropped by listen sockets, for all sockets of the host (TcpExt ListenDrops)
+# TYPE apache_listen_queue_drops_total counter
+apache_listen_queue_drops_total 61
+# HELP apache_listen_queue_length Connections waiting in the accept queue of a listen socket
+# TYPE apache_listen_queue_length gauge
+apache_listen_queue_length{address="0.0.0.0:443"} 1
+apache_listen_queue_length{address="0.0.0.0:80"} 3
+apache_listen_queue_length{address="[::]:80"} 4
+# HELP apache_listen_queue_overflows_total Times the accept queue of a listen socket was full, for all sockets of the host (TcpExt ListenOverflows)
+# TYPE apache_listen_queue_overflows_total counter
+apache_listen_queue_overflows_total 57
+# HELP apache_listen_queue_syn_drops_total SYNs dropped because the SYN queue was full, for all sockets of the host (TcpExt TCPReqQFullDrop)
+# TYPE apache_listen_queue_syn_drops_total counter
+apache_listen_queue_syn_drops_total 4
+# HELP apache_listen_queue_syncookies_sent_total SYN cookies sent because the SYN queue was full, for all sockets of the host (TcpExt SyncookiesSent)
+# TYPE apache_listen_queue_syncookies_sent_total counter
+apache_listen_queue_syncookies_sent_total 12
+# HELP apache_listen_queue_up Could the listen sockets be read from procfs
+# TYPE apache_listen_queue_up gauge
+apache_listen_queue_up 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestListenQueuePorts(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		config Config
+		ports  []int
+	}{
+		{"flag", Config{ListenQueuePorts: []int{8443}, HTTPDConfigFile: "testdata/httpd/conf/httpd.conf"}, []int{8443}},
+		{"httpd config", Config{HTTPDConfigFile: "testdata/httpd/conf/httpd.conf"}, []int{80, 443}},
+		{"scrape URI", Config{ScrapeURI: "http://localhost:8081/server-status?auto"}, []int{8081}},
+		{"https scrape URI", Config{ScrapeURI: "https://localhost/server-status?auto"}, []int{443}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ports, err := listenQueuePorts(&test.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := slices.Sorted(maps.Keys(ports)); !slices.Equal(got, test.ports) {
+				t.Errorf("got ports %v, want %v", got, test.ports)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+//go:build !linux
+
+package collector
+
+func readListenSockets(procfsPath string) ([]listenSocket, error) {
+	return nil, errProcfsUnsupported
+}
@@ -0,0 +1,185 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// logFormatNicknames are the LogFormat nicknames of the default httpd.conf.
+var logFormatNicknames = map[string]string{
+	"common":         `%h %l %u %t "%r" %>s %b`,
+	"combined":       `%h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-Agent}i"`,
+	"vhost_combined": `%v:%p %h %l %u %t "%r" %>s %O "%{Referer}i" "%{User-Agent}i"`,
+	"combinedio":     `%h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-Agent}i" %I %O`,
+}
+
+// Matches a LogFormat directive: modifiers, an optional {parameter} and the
+// directive letter, e.g. %h, %>s, %400,501{User-agent}i or %{ms}T.
+var reLogFormatDirective = regexp.MustCompile(`%!?[0-9,]*[<>]?(?:\{([^}]*)\})?([a-zA-Z%])`)
+
+// logField is a directive the access log collector uses.
+type logField int
+
+const (
+	logFieldOther logField = iota
+	logFieldVHost
+	logFieldMethod
+	logFieldRequestLine
+	logFieldFinalStatus
+	logFieldStatus
+	logFieldDurationMicros
+	logFieldDurationMillis
+	logFieldDurationSeconds
+)
+
+// logFormat parses access log lines written with an Apache LogFormat.
+type logFormat struct {
+	re     *regexp.Regexp
+	fields []logField
+}
+
+// logRecord is what the access log collector needs from a request.
+type logRecord struct {
+	vhost    string
+	method   string
+	status   string
+	duration float64
+	// hasDuration is whether the format logs the time taken.
+	hasDuration bool
+}
+
+// parseLogFormat compiles an Apache LogFormat string, or one of the nicknames
+// of the default config, into a parser. Escaped quotes, as written in
+// httpd.conf, are accepted.
+func parseLogFormat(format string) (*logFormat, error) {
+	if nickname, ok := logFormatNicknames[format]; ok {
+		format = nickname
+	}
+	format = strings.ReplaceAll(format, `\"`, `"`)
+
+	f := &logFormat{}
+	var sb strings.Builder
+	sb.WriteString("^")
+	last := 0
+	for _, m := range reLogFormatDirective.FindAllStringSubmatchIndex(format, -1) {
+		sb.WriteString(regexp.QuoteMeta(format[last:m[0]]))
+		last = m[1]
+
+		directive := format[m[0]:m[1]]
+		letter := format[m[4]:m[5]]
+		param := ""
+		if m[2] >= 0 {
+			param = format[m[2]:m[3]]
+		}
+		if letter == "%" {
+			sb.WriteString("%")
+			continue
+		}
+
+		quoted := m[0] > 0 && format[m[0]-1] == '"'
+		switch {
+		case quoted:
+			sb.WriteString(`((?:[^"\\]|\\.)*)`)
+		case letter == "t" && param == "":
+			sb.WriteString(`(\[[^\]]*\])`)
+		case strings.ContainsRune("hafAlusbBDTvVpPmHkIOSLqXR", rune(letter[0])):
+			sb.WriteString(`(\S*)`)
+		default:
+			sb.WriteString(`(.*?)`)
+		}
+
+		field := logFieldOther
+		switch letter {
+		case "v", "V":
+			field = logFieldVHost
+		case "m":
+			field = logFieldMethod
+		case "r":
+			field = logFieldRequestLine
+		case "s":
+			field = logFieldStatus
+			if strings.Contains(directive, ">") {
+				field = logFieldFinalStatus
+			}
+		case "D":
+			field = logFieldDurationMicros
+		case "T":
+			switch param {
+			case "", "s":
+				field = logFieldDurationSeconds
+			case "ms":
+				field = logFieldDurationMillis
+			case "us":
+				field = logFieldDurationMicros
+			default:
+				return nil, fmt.Errorf("invalid LogFormat directive %s", directive)
+			}
+		}
+		f.fields = append(f.fields, field)
+	}
+	sb.WriteString(regexp.QuoteMeta(format[last:]))
+	sb.WriteString("$")
+
+	var err error
+	if f.re, err = regexp.Compile(sb.String()); err != nil {
+		return nil, fmt.Errorf("invalid LogFormat %q: %w", format, err)
+	}
+	return f, nil
+}
+
+// parse reads a log line. Fields logged as "-" are left empty.
+func (f *logFormat) parse(line string) (logRecord, error) {
+	m := f.re.FindStringSubmatch(line)
+	if m == nil {
+		return logRecord{}, fmt.Errorf("line does not match LogFormat")
+	}
+
+	var r logRecord
+	for i, field := range f.fields {
+		v := m[i+1]
+		if v == "-" {
+			continue
+		}
+		switch field {
+		case logFieldVHost:
+			if r.vhost == "" {
+				r.vhost = v
+			}
+		case logFieldMethod:
+			r.method = v
+		case logFieldRequestLine:
+			if r.method == "" {
+				r.method, _, _ = strings.Cut(v, " ")
+			}
+		case logFieldFinalStatus:
+			r.status = v
+		case logFieldStatus:
+			if r.status == "" {
+				r.status = v
+			}
+		case logFieldDurationMicros, logFieldDurationMillis, logFieldDurationSeconds:
+			val, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return logRecord{}, fmt.Errorf("invalid duration %q", v)
+			}
+			switch field {
+			case logFieldDurationMicros:
+				val /= 1e6
+			case logFieldDurationMillis:
+				val /= 1e3
+			}
+			r.duration = val
+			r.hasDuration = true
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,219 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// mdStates are the states a managed domain is exported in. The first six are
+// mod_md's md_state_t in order, the last two mean a renewal job is running or
+// has finished and waits for a graceful restart.
+var mdStates = []string{"unknown", "incomplete", "complete", "expired", "error", "missing_information", "renewing", "renewed"}
+
+type mdValidity struct {
+	From  string `json:"from"`
+	Until string `json:"until"`
+}
+
+// mdCertificate is the "cert" object of a managed domain. It has the validity
+// of the certificate for each key type, and for compatibility that of the
+// first one at the top level.
+type mdCertificate struct {
+	Valid *mdValidity
+	Keys  map[string]mdValidity
+}
+
+func (c *mdCertificate) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	c.Keys = make(map[string]mdValidity)
+	for name, raw := range fields {
+		if name == "valid" {
+			if err := json.Unmarshal(raw, &c.Valid); err != nil {
+				return err
+			}
+			continue
+		}
+		var key struct {
+			Valid *mdValidity `json:"valid"`
+		}
+		if json.Unmarshal(raw, &key) == nil && key.Valid != nil {
+			c.Keys[name] = *key.Valid
+		}
+	}
+	return nil
+}
+
+// expiry returns the earliest end of validity of the certificates.
+func (c *mdCertificate) expiry() (time.Time, error) {
+	validities := make([]mdValidity, 0, len(c.Keys)+1)
+	if c.Valid != nil {
+		validities = append(validities, *c.Valid)
+	}
+	for _, v := range c.Keys {
+		validities = append(validities, v)
+	}
+
+	var earliest time.Time
+	for _, v := range validities {
+		if v.Until == "" {
+			continue
+		}
+		until, err := http.ParseTime(v.Until)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid certificate validity %q: %w", v.Until, err)
+		}
+		if earliest.IsZero() || until.Before(earliest) {
+			earliest = until
+		}
+	}
+	return earliest, nil
+}
+
+type mdRenewal struct {
+	Finished bool    `json:"finished"`
+	Errors   float64 `json:"errors"`
+}
+
+// mdDomain is a managed domain as reported by the md-status handler.
+type mdDomain struct {
+	Name    string         `json:"name"`
+	State   int            `json:"state"`
+	Cert    *mdCertificate `json:"cert"`
+	Renewal *mdRenewal     `json:"renewal"`
+}
+
+// state returns the name of the domain's state, see mdStates.
+func (d *mdDomain) state() string {
+	switch {
+	case d.Renewal != nil && d.Renewal.Finished:
+		return "renewed"
+	case d.Renewal != nil:
+		return "renewing"
+	case d.State > 0 && d.State < 6:
+		return mdStates[d.State]
+	}
+	return "unknown"
+}
+
+type mdStatus struct {
+	ManagedDomains []mdDomain `json:"managed-domains"`
+}
+
+// MDCollector scrapes the mod_md md-status handler for the state and
+// certificate expiry of managed domains.
+type MDCollector struct {
+	URI    string
+	mutex  sync.Mutex
+	http   *httpClient
+	logger *slog.Logger
+
+	up            *prometheus.Desc
+	certExpiry    *prometheus.Desc
+	renewalState  *prometheus.Desc
+	renewalErrors *prometheus.Desc
+}
+
+func NewMDCollector(logger *slog.Logger, config *Config) *MDCollector {
+	return &MDCollector{
+		URI:    config.MDStatusURI,
+		http:   newHTTPClient(config),
+		logger: logger,
+		up: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "md_status", "up"),
+			"Could the mod_md md-status handler be reached",
+			nil,
+			nil),
+		certExpiry: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "md", "cert_expiry_timestamp_seconds"),
+			"Earliest expiry of the certificates of a managed domain, in seconds since the epoch",
+			[]string{"domain"}, nil,
+		),
+		renewalState: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "md", "renewal_state"),
+			"Current state of a managed domain, including running and finished renewals",
+			[]string{"domain", "state"}, nil,
+		),
+		renewalErrors: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "md", "renewal_errors"),
+			"Consecutive failed renewal attempts of a managed domain",
+			[]string{"domain"}, nil,
+		),
+	}
+}
+
+// Describe implements Prometheus.Collector.
+func (c *MDCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up
+	ch <- c.certExpiry
+	ch <- c.renewalState
+	ch <- c.renewalErrors
+}
+
+func (c *MDCollector) collect(ch chan<- prometheus.Metric) error {
+	data, err := c.http.get(c.URI)
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+		return err
+	}
+	var status mdStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+		return fmt.Errorf("error parsing md-status: %w", err)
+	}
+	ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 1)
+
+	for _, d := range status.ManagedDomains {
+		// A validity that cannot be read only leaves out the expiry of the
+		// domain; its renewal state is still exported.
+		if d.Cert != nil {
+			expiry, err := d.Cert.expiry()
+			if err != nil {
+				c.logger.Error("Skipping certificate expiry of managed domain", "domain", d.Name, "err", err)
+			} else if !expiry.IsZero() {
+				ch <- prometheus.MustNewConstMetric(c.certExpiry, prometheus.GaugeValue, float64(expiry.Unix()), d.Name)
+			}
+		}
+
+		current := d.state()
+		for _, state := range mdStates {
+			val := 0.0
+			if state == current {
+				val = 1
+			}
+			ch <- prometheus.MustNewConstMetric(c.renewalState, prometheus.GaugeValue, val, d.Name, state)
+		}
+
+		errors := 0.0
+		if d.Renewal != nil {
+			errors = d.Renewal.Errors
+		}
+		ch <- prometheus.MustNewConstMetric(c.renewalErrors, prometheus.GaugeValue, errors, d.Name)
+	}
+
+	return nil
+}
+
+// Collect implements Prometheus.Collector.
+func (c *MDCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.collect(ch); err != nil {
+		c.logger.Error("Error scraping mod_md md-status", "err", err)
+	}
+}
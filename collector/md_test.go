@@ -0,0 +1,125 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+)
+
+func TestMDStatus(t *testing.T) {
+	page, err := os.ReadFile("testdata/md-status.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(page)
+	}))
+	defer server.Close()
+
+	c := NewMDCollector(promslog.NewNopLogger(), &Config{MDStatusURI: server.URL + "/md-status"})
+
+	expected := `
+# HELP apache_md_cert_expiry_timestamp_seconds Earliest expiry of the certificates of a managed domain, in seconds since the epoch
+# TYPE apache_md_cert_expiry_timestamp_seconds gauge
+apache_md_cert_expiry_timestamp_seconds{domain="example.com"} 1.79714952e+09
+apache_md_cert_expiry_timestamp_seconds{domain="shop.example.org"} 1.792404e+09
+# HELP apache_md_renewal_errors Consecutive failed renewal attempts of a managed domain
+# TYPE apache_md_renewal_errors gauge
+apache_md_renewal_errors{domain="example.com"} 0
+apache_md_renewal_errors{domain="new.example.net"} 0
+apache_md_renewal_errors{domain="shop.example.org"} 4
+# HELP apache_md_status_up Could the mod_md md-status handler be reached
+# TYPE apache_md_status_up gauge
+apache_md_status_up 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"apache_md_status_up", "apache_md_cert_expiry_timestamp_seconds", "apache_md_renewal_errors",
+	); err != nil {
+		t.Error(err)
+	}
+
+	states := map[string]string{
+		"example.com":      "complete",
+		"shop.example.org": "renewing",
+		"new.example.net":  "renewed",
+	}
+	var sb strings.Builder
+	sb.WriteString("# HELP apache_md_renewal_state Current state of a managed domain, including running and finished renewals\n")
+	sb.WriteString("# TYPE apache_md_renewal_state gauge\n")
+	for _, domain := range []string{"example.com", "new.example.net", "shop.example.org"} {
+		for _, state := range mdStates {
+			val := "0"
+			if states[domain] == state {
+				val = "1"
+			}
+			sb.WriteString(`apache_md_renewal_state{domain="` + domain + `",state="` + state + `"} ` + val + "\n")
+		}
+	}
+	if err := testutil.CollectAndCompare(c, strings.NewReader(sb.String()), "apache_md_renewal_state"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMDStatusInvalidValidity(t *testing.T) {
+	page := `{"managed-domains": [
+  {"name": "broken.example.com", "state": 4, "cert": {"valid": {"until": "next Tuesday"}}, "renewal": {"errors": 3}},
+  {"name": "example.com", "state": 2, "cert": {"valid": {"until": "Sun, 13 Dec 2026 08:12:00 GMT"}}}
+]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(page))
+	}))
+	defer server.Close()
+
+	c := NewMDCollector(promslog.NewNopLogger(), &Config{MDStatusURI: server.URL + "/md-status"})
+
+	expected := `
+# HELP apache_md_cert_expiry_timestamp_seconds Earliest expiry of the certificates of a managed domain, in seconds since the epoch
+# TYPE apache_md_cert_expiry_timestamp_seconds gauge
+apache_md_cert_expiry_timestamp_seconds{domain="example.com"} 1.79714952e+09
+# HELP apache_md_renewal_errors Consecutive failed renewal attempts of a managed domain
+# TYPE apache_md_renewal_errors gauge
+apache_md_renewal_errors{domain="broken.example.com"} 3
+apache_md_renewal_errors{domain="example.com"} 0
+# HELP apache_md_status_up Could the mod_md md-status handler be reached
+# TYPE apache_md_status_up gauge
+apache_md_status_up 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"apache_md_status_up", "apache_md_cert_expiry_timestamp_seconds", "apache_md_renewal_errors",
+	); err != nil {
+		t.Error(err)
+	}
+
+	states := map[string]string{
+		"broken.example.com": "renewing",
+		"example.com":        "complete",
+	}
+	var sb strings.Builder
+	sb.WriteString("# HELP apache_md_renewal_state Current state of a managed domain, including running and finished renewals\n")
+	sb.WriteString("# TYPE apache_md_renewal_state gauge\n")
+	for _, domain := range []string{"broken.example.com", "example.com"} {
+		for _, state := range mdStates {
+			val := "0"
+			if states[domain] == state {
+				val = "1"
+			}
+			sb.WriteString(`apache_md_renewal_state{domain="` + domain + `",state="` + state + `"} ` + val + "\n")
+		}
+	}
+	if err := testutil.CollectAndCompare(c, strings.NewReader(sb.String()), "apache_md_renewal_state"); err != nil {
+		t.Error(err)
+	}
+}
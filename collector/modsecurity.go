@@ -0,0 +1,409 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// Matches the section boundaries of the native audit log format, e.g.
+	// "--a1b2c3d4-A--" (ModSecurity 2) or "---a1b2c3d4---A--" (ModSecurity 3).
+	reModSecBoundary = regexp.MustCompile(`^-{2,}([0-9A-Za-z]+)-{1,}([A-Z])--$`)
+	// Matches a line of the concurrent audit log index up to the entry file:
+	// host, remote address, users, time, request line, status, bytes,
+	// referer, user agent, unique ID, session ID and file.
+	reModSecIndex = regexp.MustCompile(`^(\S+) \S+ \S+ \S+ \[[^\]]*\] "(?:[^"\\]|\\.)*" \d+ \S+ "(?:[^"\\]|\\.)*" "(?:[^"\\]|\\.)*" \S+ "(?:[^"\\]|\\.)*" (/\S+) `)
+	// Match the rule ID and severity of an audit log message.
+	reModSecRuleID   = regexp.MustCompile(`\[id "([^"]*)"\]`)
+	reModSecSeverity = regexp.MustCompile(`\[severity "([^"]*)"\]`)
+)
+
+// modSecSeverities are the names of ModSecurity's numeric severities.
+var modSecSeverities = []string{"EMERGENCY", "ALERT", "CRITICAL", "ERROR", "WARNING", "NOTICE", "INFO", "DEBUG"}
+
+// modSecTransaction is what the collector needs from an audit log entry.
+type modSecTransaction struct {
+	vhost       string
+	intercepted bool
+	rules       []modSecRuleHit
+}
+
+type modSecRuleHit struct {
+	id       string
+	severity string
+}
+
+// modSecRuleHits counts the hits of a rule ID by severity.
+type modSecRuleHits struct {
+	// order is the position of the rule ID among those seen, which breaks
+	// ties between rules with as many hits.
+	order      int
+	total      float64
+	bySeverity map[string]float64
+}
+
+// action returns how ModSecurity handled the transaction: blocked, detected
+// (rules matched but the request was let through) or allowed.
+func (t *modSecTransaction) action() string {
+	switch {
+	case t.intercepted:
+		return "blocked"
+	case len(t.rules) > 0:
+		return "detected"
+	}
+	return "allowed"
+}
+
+// addMessage records the rule hit of an audit log message, e.g.
+// `Warning. ... [id "942100"] [msg "SQL Injection Attack"] [severity "CRITICAL"]`.
+func (t *modSecTransaction) addMessage(msg string) {
+	m := reModSecRuleID.FindStringSubmatch(msg)
+	if m == nil {
+		return
+	}
+	hit := modSecRuleHit{id: m[1]}
+	if m := reModSecSeverity.FindStringSubmatch(msg); m != nil {
+		hit.severity = modSecSeverity(m[1])
+	}
+	t.rules = append(t.rules, hit)
+}
+
+// modSecSeverity returns the name of a severity, which ModSecurity 3 logs as
+// a number.
+func modSecSeverity(s string) string {
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 && n < len(modSecSeverities) {
+		return modSecSeverities[n]
+	}
+	return strings.ToUpper(s)
+}
+
+// hostLabel returns a Host header without its port.
+func hostLabel(host string) string {
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.HasSuffix(host, "]") {
+		host = host[:i]
+	}
+	return strings.ToLower(host)
+}
+
+// modSecNativeParser assembles audit log entries in the native format from
+// their lines.
+type modSecNativeParser struct {
+	// section is the current section letter, 0 outside of an entry.
+	section byte
+	entry   *modSecTransaction
+}
+
+// line feeds a line of the audit log, returning the entry it completes, if
+// any.
+func (p *modSecNativeParser) line(line string) *modSecTransaction {
+	if m := reModSecBoundary.FindStringSubmatch(line); m != nil {
+		p.section = m[2][0]
+		switch p.section {
+		case 'A':
+			p.entry = &modSecTransaction{}
+		case 'Z':
+			entry := p.entry
+			p.section, p.entry = 0, nil
+			return entry
+		}
+		return nil
+	}
+	if p.entry == nil {
+		return nil
+	}
+
+	switch p.section {
+	case 'B':
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, "Host") {
+			p.entry.vhost = hostLabel(strings.TrimSpace(value))
+		}
+	case 'H':
+		switch {
+		case strings.HasPrefix(line, "Message: "):
+			p.entry.addMessage(line)
+		case strings.HasPrefix(line, "Action: Intercepted"):
+			p.entry.intercepted = true
+		}
+	}
+	return nil
+}
+
+// parseModSecNative parses the entries of a native format audit log file.
+func parseModSecNative(data []byte) []*modSecTransaction {
+	var (
+		p       modSecNativeParser
+		entries []*modSecTransaction
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if entry := p.line(scanner.Text()); entry != nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// parseModSecJSON parses an audit log entry in the JSON format of
+// ModSecurity 2 or 3.
+func parseModSecJSON(data []byte) (*modSecTransaction, error) {
+	var entry struct {
+		// ModSecurity 2
+		Request struct {
+			Headers map[string]string `json:"headers"`
+		} `json:"request"`
+		AuditData struct {
+			Messages []string `json:"messages"`
+			Action   struct {
+				Intercepted bool `json:"intercepted"`
+			} `json:"action"`
+		} `json:"audit_data"`
+		// ModSecurity 3
+		Transaction struct {
+			Request struct {
+				Headers map[string]string `json:"headers"`
+			} `json:"request"`
+			Response struct {
+				HTTPCode int `json:"http_code"`
+			} `json:"response"`
+			Messages []struct {
+				Details struct {
+					RuleID   string `json:"ruleId"`
+					Severity string `json:"severity"`
+				} `json:"details"`
+			} `json:"messages"`
+		} `json:"transaction"`
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+
+	t := &modSecTransaction{intercepted: entry.AuditData.Action.Intercepted}
+	headers := entry.Request.Headers
+	if headers == nil {
+		headers = entry.Transaction.Request.Headers
+	}
+	for name, value := range headers {
+		if strings.EqualFold(name, "Host") {
+			t.vhost = hostLabel(value)
+		}
+	}
+	for _, msg := range entry.AuditData.Messages {
+		t.addMessage(msg)
+	}
+	for _, msg := range entry.Transaction.Messages {
+		t.rules = append(t.rules, modSecRuleHit{id: msg.Details.RuleID, severity: modSecSeverity(msg.Details.Severity)})
+	}
+	// ModSecurity 3 does not log whether it intercepted the transaction;
+	// an error status with matched rules is taken to mean it did.
+	if len(entry.Transaction.Messages) > 0 && entry.Transaction.Response.HTTPCode >= 400 {
+		t.intercepted = true
+	}
+	return t, nil
+}
+
+// ModSecurityCollector counts the transactions and rule hits of the
+// ModSecurity audit log.
+type ModSecurityCollector struct {
+	storageDir string
+	vhosts     *labelLimiter
+	maxRules   int
+	logger     *slog.Logger
+
+	tailer *tailer
+	native modSecNativeParser
+
+	mutex sync.Mutex
+	rules map[string]*modSecRuleHits
+
+	transactions *prometheus.CounterVec
+	ruleHits     *prometheus.Desc
+	parseErrors  prometheus.Counter
+}
+
+// NewModSecurityCollector returns a collector tailing
+// config.ModSecurityAuditLog until Close is called. The audit log may be a
+// serial log in the native or JSON format, or with
+// config.ModSecurityStorageDir set, the index of a concurrent log.
+func NewModSecurityCollector(logger *slog.Logger, config *Config) *ModSecurityCollector {
+	c := &ModSecurityCollector{
+		storageDir: config.ModSecurityStorageDir,
+		vhosts:     newLabelLimiter(config.ModSecurityMaxVHosts),
+		maxRules:   config.ModSecurityMaxRules,
+		logger:     logger,
+		rules:      make(map[string]*modSecRuleHits),
+		transactions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "modsecurity_transactions_total",
+			Help:      "Transactions in the ModSecurity audit log by action (blocked, detected, allowed)",
+		},
+			[]string{"action", "vhost"},
+		),
+		ruleHits: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "modsecurity", "rule_hits_total"),
+			"ModSecurity rule matches in the audit log by rule ID and severity",
+			[]string{"rule_id", "severity"}, nil,
+		),
+		parseErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "modsecurity_audit_log_parse_errors_total",
+			Help:      "ModSecurity audit log entries that could not be read or parsed",
+		}),
+	}
+
+	if config.ModSecurityAuditLog != "" {
+		c.tailer = newTailer(logger, config.ModSecurityAuditLog, c.handleLine)
+		go c.tailer.run()
+	}
+	return c
+}
+
+// Close stops tailing the audit log.
+func (c *ModSecurityCollector) Close() {
+	if c.tailer != nil {
+		c.tailer.close()
+	}
+}
+
+// handleLine handles a line of the audit log.
+func (c *ModSecurityCollector) handleLine(line string) {
+	switch {
+	case c.storageDir != "":
+		if err := c.readEntryFile(line); err != nil {
+			c.parseErrors.Inc()
+			c.logger.Debug("Error reading ModSecurity audit log entry", "err", err)
+		}
+	case strings.HasPrefix(line, "{"):
+		t, err := parseModSecJSON([]byte(line))
+		if err != nil {
+			c.parseErrors.Inc()
+			c.logger.Debug("Error parsing ModSecurity audit log entry", "err", err)
+			return
+		}
+		c.observe(t)
+	default:
+		if t := c.native.line(line); t != nil {
+			c.observe(t)
+		}
+	}
+}
+
+// readEntryFile reads the entry file an index line of a concurrent audit log
+// refers to.
+func (c *ModSecurityCollector) readEntryFile(line string) error {
+	m := reModSecIndex.FindStringSubmatch(line)
+	if m == nil {
+		return fmt.Errorf("invalid audit log index line %q", line)
+	}
+	data, err := os.ReadFile(filepath.Join(c.storageDir, filepath.Clean(m[2])))
+	if err != nil {
+		return err
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		t, err := parseModSecJSON(data)
+		if err != nil {
+			return err
+		}
+		c.observe(t)
+		return nil
+	}
+	entries := parseModSecNative(data)
+	if len(entries) == 0 {
+		return fmt.Errorf("no audit log entry in %s", m[2])
+	}
+	for _, t := range entries {
+		if t.vhost == "" {
+			t.vhost = hostLabel(m[1])
+		}
+		c.observe(t)
+	}
+	return nil
+}
+
+// observe counts a transaction, bounding the vhost label to the first values
+// seen. Rule hits are counted by rule ID and bounded at collect time.
+func (c *ModSecurityCollector) observe(t *modSecTransaction) {
+	c.transactions.WithLabelValues(t.action(), c.vhosts.value(t.vhost)).Inc()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, hit := range t.rules {
+		r, ok := c.rules[hit.id]
+		if !ok {
+			r = &modSecRuleHits{order: len(c.rules), bySeverity: make(map[string]float64)}
+			c.rules[hit.id] = r
+		}
+		r.total++
+		r.bySeverity[hit.severity]++
+	}
+}
+
+// collectRuleHits exports the hits of the maxRules most hit rule IDs and sums
+// those of the other rules into rule_id "other".
+func (c *ModSecurityCollector) collectRuleHits(ch chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	ids := make([]string, 0, len(c.rules))
+	for id := range c.rules {
+		ids = append(ids, id)
+	}
+	slices.SortFunc(ids, func(a, b string) int {
+		ra, rb := c.rules[a], c.rules[b]
+		if ra.total != rb.total {
+			if ra.total > rb.total {
+				return -1
+			}
+			return 1
+		}
+		return ra.order - rb.order
+	})
+
+	other := make(map[string]float64)
+	for i, id := range ids {
+		for severity, n := range c.rules[id].bySeverity {
+			if c.maxRules > 0 && i >= c.maxRules {
+				other[severity] += n
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(c.ruleHits, prometheus.CounterValue, n, id, severity)
+		}
+	}
+	for severity, n := range other {
+		ch <- prometheus.MustNewConstMetric(c.ruleHits, prometheus.CounterValue, n, "other", severity)
+	}
+}
+
+// Describe implements Prometheus.Collector.
+func (c *ModSecurityCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.transactions.Describe(ch)
+	ch <- c.ruleHits
+	c.parseErrors.Describe(ch)
+}
+
+// Collect implements Prometheus.Collector.
+func (c *ModSecurityCollector) Collect(ch chan<- prometheus.Metric) {
+	c.transactions.Collect(ch)
+	c.collectRuleHits(ch)
+	c.parseErrors.Collect(ch)
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+)
+
+// feedModSecurityLog hands the lines of an audit log fixture to c.
+func feedModSecurityLog(t *testing.T, c *ModSecurityCollector, path string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		c.handleLine(line)
+	}
+}
+
+func TestModSecurityCollectorNative(t *testing.T) {
+	c := NewModSecurityCollector(promslog.NewNopLogger(), &Config{ModSecurityMaxRules: 3})
+	feedModSecurityLog(t, c, "testdata/modsecurity/audit.log")
+
+	expected := `
+# HELP apache_modsecurity_audit_log_parse_errors_total ModSecurity audit log entries that could not be read or parsed
+# TYPE apache_modsecurity_audit_log_parse_errors_total counter
+apache_modsecurity_audit_log_parse_errors_total 0
+# HELP apache_modsecurity_rule_hits_total ModSecurity rule matches in the audit log by rule ID and severity
+# TYPE apache_modsecurity_rule_hits_total counter
+apache_modsecurity_rule_hits_total{rule_id="941110",severity="CRITICAL"} 1
+apache_modsecurity_rule_hits_total{rule_id="942100",severity="CRITICAL"} 1
+apache_modsecurity_rule_hits_total{rule_id="949110",severity="CRITICAL"} 1
+apache_modsecurity_rule_hits_total{rule_id="other",severity="WARNING"} 1
+# HELP apache_modsecurity_transactions_total Transactions in the ModSecurity audit log by action (blocked, detected, allowed)
+# TYPE apache_modsecurity_transactions_total counter
+apache_modsecurity_transactions_total{action="allowed",vhost="www.example.com"} 1
+apache_modsecurity_transactions_total{action="blocked",vhost="www.example.com"} 1
+apache_modsecurity_transactions_total{action="detected",vhost="shop.example.com"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModSecurityCollectorTopRules(t *testing.T) {
+	c := NewModSecurityCollector(promslog.NewNopLogger(), &Config{ModSecurityMaxRules: 2})
+	hits := []modSecRuleHit{
+		{id: "920350", severity: "WARNING"},
+		{id: "942100", severity: "CRITICAL"},
+		{id: "942100", severity: "CRITICAL"},
+		{id: "913100", severity: "WARNING"},
+		{id: "949110", severity: "CRITICAL"},
+		{id: "949110", severity: "CRITICAL"},
+		{id: "949110", severity: "CRITICAL"},
+		{id: "941110", severity: "CRITICAL"},
+	}
+	for _, hit := range hits {
+		c.observe(&modSecTransaction{vhost: "www.example.com", rules: []modSecRuleHit{hit}})
+	}
+
+	// Rules first seen later still make the top 2 if they are hit most.
+	expected := `
+# HELP apache_modsecurity_rule_hits_total ModSecurity rule matches in the audit log by rule ID and severity
+# TYPE apache_modsecurity_rule_hits_total counter
+apache_modsecurity_rule_hits_total{rule_id="942100",severity="CRITICAL"} 2
+apache_modsecurity_rule_hits_total{rule_id="949110",severity="CRITICAL"} 3
+apache_modsecurity_rule_hits_total{rule_id="other",severity="CRITICAL"} 1
+apache_modsecurity_rule_hits_total{rule_id="other",severity="WARNING"} 2
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "apache_modsecurity_rule_hits_total"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModSecurityCollectorJSON(t *testing.T) {
+	c := NewModSecurityCollector(promslog.NewNopLogger(), &Config{})
+	feedModSecurityLog(t, c, "testdata/modsecurity/audit.json")
+
+	expected := `
+# HELP apache_modsecurity_audit_log_parse_errors_total ModSecurity audit log entries that could not be read or parsed
+# TYPE apache_modsecurity_audit_log_parse_errors_total counter
+apache_modsecurity_audit_log_parse_errors_total 1
+# HELP apache_modsecurity_rule_hits_total ModSecurity rule matches in the audit log by rule ID and severity
+# TYPE apache_modsecurity_rule_hits_total counter
+apache_modsecurity_rule_hits_total{rule_id="930120",severity="CRITICAL"} 1
+apache_modsecurity_rule_hits_total{rule_id="941110",severity="CRITICAL"} 1
+# HELP apache_modsecurity_transactions_total Transactions in the ModSecurity audit log by action (blocked, detected, allowed)
+# TYPE apache_modsecurity_transactions_total counter
+apache_modsecurity_transactions_total{action="allowed",vhost="www.example.com"} 1
+apache_modsecurity_transactions_total{action="blocked",vhost="shop.example.com"} 1
+apache_modsecurity_transactions_total{action="blocked",vhost="www.example.com"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModSecurityCollectorConcurrent(t *testing.T) {
+	c := NewModSecurityCollector(promslog.NewNopLogger(), &Config{ModSecurityStorageDir: "testdata/modsecurity"})
+	feedModSecurityLog(t, c, "testdata/modsecurity/index")
+
+	expected := `
+# HELP apache_modsecurity_audit_log_parse_errors_total ModSecurity audit log entries that could not be read or parsed
+# TYPE apache_modsecurity_audit_log_parse_errors_total counter
+apache_modsecurity_audit_log_parse_errors_total 1
+# HELP apache_modsecurity_transactions_total Transactions in the ModSecurity audit log by action (blocked, detected, allowed)
+# TYPE apache_modsecurity_transactions_total counter
+apache_modsecurity_transactions_total{action="blocked",vhost="www.example.com"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"apache_modsecurity_audit_log_parse_errors_total", "apache_modsecurity_transactions_total"); err != nil {
+		t.Error(err)
+	}
+}
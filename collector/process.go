@@ -0,0 +1,251 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// processStats are the resources used by an httpd process.
+type processStats struct {
+	pid  int
+	role string
+
+	residentMemory float64
+	// proportionalMemory is only read when hasPSS is set, as smaps_rollup
+	// may not be readable.
+	proportionalMemory float64
+	hasPSS             bool
+	// openFDs is only read when hasFDs is set, as the fd directory may not
+	// be readable either.
+	openFDs             float64
+	hasFDs              bool
+	maxFDs              float64
+	threads             float64
+	cpuSeconds          float64
+	voluntarySwitches   float64
+	involuntarySwitches float64
+}
+
+// readPidFile reads the PID of the httpd parent from a PidFile.
+func readPidFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid PID in %s: %w", path, err)
+	}
+	return pid, nil
+}
+
+// ProcessCollector exports the memory, file descriptors, threads and context
+// switches of the httpd parent and its children, read from procfs.
+type ProcessCollector struct {
+	pidFile    string
+	procfsPath string
+	statusURI  string
+	mutex      sync.Mutex
+	http       *httpClient
+	logger     *slog.Logger
+
+	up                 *prometheus.Desc
+	processes          *prometheus.Desc
+	residentMemory     *prometheus.Desc
+	proportionalMemory *prometheus.Desc
+	openFDs            *prometheus.Desc
+	maxFDs             *prometheus.Desc
+	threads            *prometheus.Desc
+	cpuSeconds         *prometheus.Desc
+	contextSwitches    *prometheus.Desc
+}
+
+// NewProcessCollector returns a collector for the processes of the httpd
+// whose parent PID is in config.ProcessPidFile or, without a pidfile, the
+// parent of the PIDs on the server-status page. Only Linux is supported.
+func NewProcessCollector(logger *slog.Logger, config *Config) (*ProcessCollector, error) {
+	if err := checkProcfs(config.ProcfsPath); err != nil {
+		return nil, err
+	}
+	c := &ProcessCollector{
+		pidFile:    config.ProcessPidFile,
+		procfsPath: config.ProcfsPath,
+		http:       newHTTPClient(config),
+		logger:     logger,
+		up: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "process", "up"),
+			"Could the httpd processes be found and read from procfs",
+			nil,
+			nil),
+		processes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "process", "count"),
+			"httpd processes by role (parent, child)",
+			[]string{"role"}, nil,
+		),
+		residentMemory: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "process", "resident_memory_bytes"),
+			"Resident set size of an httpd process",
+			[]string{"pid", "role"}, nil,
+		),
+		proportionalMemory: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "process", "proportional_memory_bytes"),
+			"Proportional set size of an httpd process, sharing pages mapped by several processes between them",
+			[]string{"pid", "role"}, nil,
+		),
+		openFDs: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "process", "open_fds"),
+			"Open file descriptors of an httpd process",
+			[]string{"pid", "role"}, nil,
+		),
+		maxFDs: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "process", "max_fds"),
+			"Limit of open file descriptors of an httpd process",
+			[]string{"pid", "role"}, nil,
+		),
+		threads: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "process", "threads"),
+			"Threads of an httpd process",
+			[]string{"pid", "role"}, nil,
+		),
+		cpuSeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "process", "cpu_seconds_total"),
+			"User and system CPU time of an httpd process",
+			[]string{"pid", "role"}, nil,
+		),
+		contextSwitches: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "process", "context_switches_total"),
+			"Context switches of an httpd process by type (voluntary, involuntary)",
+			[]string{"pid", "role", "type"}, nil,
+		),
+	}
+	if c.pidFile == "" {
+		c.statusURI = config.StatusPageURI
+		if c.statusURI == "" {
+			var err error
+			if c.statusURI, err = statusPageURI(config.ScrapeURI); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return c, nil
+}
+
+// Describe implements Prometheus.Collector.
+func (c *ProcessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up
+	ch <- c.processes
+	ch <- c.residentMemory
+	ch <- c.proportionalMemory
+	ch <- c.openFDs
+	ch <- c.maxFDs
+	ch <- c.threads
+	ch <- c.cpuSeconds
+	ch <- c.contextSwitches
+}
+
+// statusPagePIDs returns the PIDs of the children on the server-status page.
+func (c *ProcessCollector) statusPagePIDs() ([]int, error) {
+	data, err := c.http.get(c.statusURI)
+	if err != nil {
+		return nil, err
+	}
+	page, err := parseStatusPage(data)
+	if err != nil {
+		return nil, err
+	}
+	if !page.extended {
+		return nil, errNoWorkerTable
+	}
+	var pids []int
+	seen := make(map[int]bool)
+	for _, w := range page.workers {
+		pid, err := strconv.Atoi(w.pid)
+		if err != nil || seen[pid] {
+			continue
+		}
+		seen[pid] = true
+		pids = append(pids, pid)
+	}
+	if len(pids) == 0 {
+		return nil, errors.New("no PIDs on the server-status page")
+	}
+	return pids, nil
+}
+
+// parentPID returns the PID of the httpd parent.
+func (c *ProcessCollector) parentPID() (int, error) {
+	if c.pidFile != "" {
+		return readPidFile(c.pidFile)
+	}
+	pids, err := c.statusPagePIDs()
+	if err != nil {
+		return 0, fmt.Errorf("error finding httpd children on the server-status page: %w", err)
+	}
+	// Children may exit between the scrape and reading procfs, so use the
+	// first one that can still be read.
+	for _, pid := range pids {
+		if ppid, err := readParentPID(c.procfsPath, pid); err == nil {
+			return ppid, nil
+		}
+	}
+	return 0, fmt.Errorf("none of the PIDs on the server-status page are in %s", c.procfsPath)
+}
+
+func (c *ProcessCollector) collect(ch chan<- prometheus.Metric) error {
+	parent, err := c.parentPID()
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+		return err
+	}
+	stats, err := readProcesses(c.logger, c.procfsPath, parent)
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 1)
+
+	roles := map[string]float64{"parent": 0, "child": 0}
+	for _, s := range stats {
+		roles[s.role]++
+		pid := strconv.Itoa(s.pid)
+		ch <- prometheus.MustNewConstMetric(c.residentMemory, prometheus.GaugeValue, s.residentMemory, pid, s.role)
+		if s.hasPSS {
+			ch <- prometheus.MustNewConstMetric(c.proportionalMemory, prometheus.GaugeValue, s.proportionalMemory, pid, s.role)
+		}
+		if s.hasFDs {
+			ch <- prometheus.MustNewConstMetric(c.openFDs, prometheus.GaugeValue, s.openFDs, pid, s.role)
+		}
+		ch <- prometheus.MustNewConstMetric(c.maxFDs, prometheus.GaugeValue, s.maxFDs, pid, s.role)
+		ch <- prometheus.MustNewConstMetric(c.threads, prometheus.GaugeValue, s.threads, pid, s.role)
+		ch <- prometheus.MustNewConstMetric(c.cpuSeconds, prometheus.CounterValue, s.cpuSeconds, pid, s.role)
+		ch <- prometheus.MustNewConstMetric(c.contextSwitches, prometheus.CounterValue, s.voluntarySwitches, pid, s.role, "voluntary")
+		ch <- prometheus.MustNewConstMetric(c.contextSwitches, prometheus.CounterValue, s.involuntarySwitches, pid, s.role, "involuntary")
+	}
+	for role, n := range roles {
+		ch <- prometheus.MustNewConstMetric(c.processes, prometheus.GaugeValue, n, role)
+	}
+	return nil
+}
+
+// Collect implements Prometheus.Collector.
+func (c *ProcessCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.collect(ch); err != nil {
+		c.logger.Error("Error reading httpd processes", "err", err)
+	}
+}
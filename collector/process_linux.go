@@ -0,0 +1,121 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+//go:build linux
+
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/prometheus/procfs"
+)
+
+// checkProcfs returns an error if procfs is not mounted at path.
+func checkProcfs(path string) error {
+	_, err := procfs.NewFS(path)
+	return err
+}
+
+// readParentPID returns the parent PID of a process.
+func readParentPID(procfsPath string, pid int) (int, error) {
+	fs, err := procfs.NewFS(procfsPath)
+	if err != nil {
+		return 0, err
+	}
+	p, err := fs.Proc(pid)
+	if err != nil {
+		return 0, err
+	}
+	stat, err := p.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return stat.PPID, nil
+}
+
+// readProcesses reads the resources of the httpd parent and its children.
+// Children exiting while they are read are left out.
+func readProcesses(logger *slog.Logger, procfsPath string, parent int) ([]processStats, error) {
+	fs, err := procfs.NewFS(procfsPath)
+	if err != nil {
+		return nil, err
+	}
+	p, err := fs.Proc(parent)
+	if err != nil {
+		return nil, fmt.Errorf("error reading httpd parent: %w", err)
+	}
+	s, err := readProcess(p, "parent")
+	if err != nil {
+		return nil, fmt.Errorf("error reading httpd parent %d: %w", parent, err)
+	}
+	stats := []processStats{s}
+
+	procs, err := fs.AllProcs()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range procs {
+		stat, err := p.Stat()
+		if err != nil || stat.PPID != parent {
+			continue
+		}
+		s, err := readProcess(p, "child")
+		if err != nil {
+			logger.Debug("Error reading httpd child", "pid", p.PID, "err", err)
+			continue
+		}
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
+
+// readProcess reads the resources of a process.
+func readProcess(p procfs.Proc, role string) (processStats, error) {
+	s := processStats{pid: p.PID, role: role}
+
+	stat, err := p.Stat()
+	if err != nil {
+		return s, err
+	}
+	s.threads = float64(stat.NumThreads)
+	s.cpuSeconds = stat.CPUTime()
+
+	status, err := p.NewStatus()
+	if err != nil {
+		return s, err
+	}
+	s.residentMemory = float64(status.VmRSS)
+	s.voluntarySwitches = float64(status.VoluntaryCtxtSwitches)
+	s.involuntarySwitches = float64(status.NonVoluntaryCtxtSwitches)
+
+	// Like smaps_rollup, the fd directory is only readable by the process
+	// owner or root.
+	fds, err := p.FileDescriptorsLen()
+	switch {
+	case err == nil:
+		s.openFDs = float64(fds)
+		s.hasFDs = true
+	case !errors.Is(err, os.ErrPermission):
+		return s, err
+	}
+	limits, err := p.Limits()
+	if err != nil {
+		return s, err
+	}
+	s.maxFDs = float64(limits.OpenFiles)
+
+	// smaps_rollup is only readable by the process owner or root.
+	if rollup, err := p.ProcSMapsRollup(); err == nil {
+		s.proportionalMemory = float64(rollup.Pss)
+		s.hasPSS = true
+	}
+	return s, nil
+}
@@ -0,0 +1,167 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+//go:build linux
+
+package collector
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+)
+
+const processMetrics = `
+# HELP apache_process_context_switches_total Context switches of an httpd process by type (voluntary, involuntary)
+# TYPE apache_process_context_switches_total counter
+apache_process_context_switches_total{pid="4210",role="parent",type="involuntary"} 20
+apache_process_context_switches_total{pid="4210",role="parent",type="voluntary"} 5000
+apache_process_context_switches_total{pid="4211",role="child",type="involuntary"} 1500
+apache_process_context_switches_total{pid="4211",role="child",type="voluntary"} 80000
+apache_process_context_switches_total{pid="4212",role="child",type="involuntary"} 700
+apache_process_context_switches_total{pid="4212",role="child",type="voluntary"} 30000
+apache_process_context_switches_total{pid="4213",role="child",type="involuntary"} 30
+apache_process_context_switches_total{pid="4213",role="child",type="voluntary"} 1200
+# HELP apache_process_cpu_seconds_total User and system CPU time of an httpd process
+# TYPE apache_process_cpu_seconds_total counter
+apache_process_cpu_seconds_total{pid="4210",role="parent"} 15
+apache_process_cpu_seconds_total{pid="4211",role="child"} 30
+apache_process_cpu_seconds_total{pid="4212",role="child"} 12
+apache_process_cpu_seconds_total{pid="4213",role="child"} 1.5
+# HELP apache_process_max_fds Limit of open file descriptors of an httpd process
+# TYPE apache_process_max_fds gauge
+apache_process_max_fds{pid="4210",role="parent"} 8192
+apache_process_max_fds{pid="4211",role="child"} 8192
+apache_process_max_fds{pid="4212",role="child"} 8192
+apache_process_max_fds{pid="4213",role="child"} 8192
+# HELP apache_process_open_fds Open file descriptors of an httpd process
+# TYPE apache_process_open_fds gauge
+apache_process_open_fds{pid="4210",role="parent"} 6
+apache_process_open_fds{pid="4211",role="child"} 12
+apache_process_open_fds{pid="4212",role="child"} 9
+apache_process_open_fds{pid="4213",role="child"} 7
+# HELP apache_process_proportional_memory_bytes Proportional set size of an httpd process, sharing pages mapped by several processes between them
+# TYPE apache_process_proportional_memory_bytes gauge
+apache_process_proportional_memory_bytes{pid="4210",role="parent"} 4.194304e+06
+apache_process_proportional_memory_bytes{pid="4211",role="child"} 4.194304e+07
+apache_process_proportional_memory_bytes{pid="4212",role="child"} 1.2582912e+07
+# HELP apache_process_resident_memory_bytes Resident set size of an httpd process
+# TYPE apache_process_resident_memory_bytes gauge
+apache_process_resident_memory_bytes{pid="4210",role="parent"} 1.048576e+07
+apache_process_resident_memory_bytes{pid="4211",role="child"} 5.24288e+07
+apache_process_resident_memory_bytes{pid="4212",role="child"} 2.097152e+07
+apache_process_resident_memory_bytes{pid="4213",role="child"} 8.388608e+06
+# HELP apache_process_threads Threads of an httpd process
+# TYPE apache_process_threads gauge
+apache_process_threads{pid="4210",role="parent"} 1
+apache_process_threads{pid="4211",role="child"} 27
+apache_process_threads{pid="4212",role="child"} 27
+apache_process_threads{pid="4213",role="child"} 27
+# HELP apache_process_up Could the httpd processes be found and read from procfs
+# TYPE apache_process_up gauge
+apache_process_up 1
+# HELP apache_process_count httpd processes by role (parent, child)
+# TYPE apache_process_count gauge
+apache_process_count{role="child"} 3
+apache_process_count{role="parent"} 1
+`
+
+func TestProcessCollectorPidFile(t *testing.T) {
+	c, err := NewProcessCollector(promslog.NewNopLogger(), &Config{
+		ProcessPidFile: "testdata/httpd.pid",
+		ProcfsPath:     "testdata/proc",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := testutil.CollectAndCompare(c, strings.NewReader(processMetrics)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProcessCollectorStatusPage(t *testing.T) {
+	page, err := os.ReadFile("testdata/server-status.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(page)
+	}))
+	defer server.Close()
+
+	c, err := NewProcessCollector(promslog.NewNopLogger(), &Config{
+		ScrapeURI:  server.URL + "/server-status?auto",
+		ProcfsPath: "testdata/proc",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := testutil.CollectAndCompare(c, strings.NewReader(processMetrics)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProcessCollectorMissingParent(t *testing.T) {
+	c, err := NewProcessCollector(promslog.NewNopLogger(), &Config{
+		ProcessPidFile: "testdata/httpd.pid",
+		ProcfsPath:     t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+# HELP apache_process_up Could the httpd processes be found and read from procfs
+# TYPE apache_process_up gauge
+apache_process_up 0
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProcessCollectorUnreadableFDs(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can read any fd directory")
+	}
+	procfsPath := filepath.Join(t.TempDir(), "proc")
+	if err := os.CopyFS(procfsPath, os.DirFS("testdata/proc")); err != nil {
+		t.Fatal(err)
+	}
+	fdDir := filepath.Join(procfsPath, "4212", "fd")
+	if err := os.Chmod(fdDir, 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(fdDir, 0o755) })
+
+	c, err := NewProcessCollector(promslog.NewNopLogger(), &Config{
+		ProcessPidFile: "testdata/httpd.pid",
+		ProcfsPath:     procfsPath,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+# HELP apache_process_open_fds Open file descriptors of an httpd process
+# TYPE apache_process_open_fds gauge
+apache_process_open_fds{pid="4210",role="parent"} 6
+apache_process_open_fds{pid="4211",role="child"} 12
+apache_process_open_fds{pid="4213",role="child"} 7
+# HELP apache_process_count httpd processes by role (parent, child)
+# TYPE apache_process_count gauge
+apache_process_count{role="child"} 3
+apache_process_count{role="parent"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "apache_process_open_fds", "apache_process_count"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+//go:build !linux
+
+package collector
+
+import (
+	"errors"
+	"log/slog"
+)
+
+var errProcfsUnsupported = errors.New("per-process metrics are only supported on Linux")
+
+func checkProcfs(path string) error {
+	return errProcfsUnsupported
+}
+
+func readParentPID(procfsPath string, pid int) (int, error) {
+	return 0, errProcfsUnsupported
+}
+
+func readProcesses(logger *slog.Logger, procfsPath string, parent int) ([]processStats, error) {
+	return nil, errProcfsUnsupported
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.yaml.in/yaml/v2"
+)
+
+// RelabelConfig rewrites or filters proxy balancer and worker names before
+// they are used as label values.
+type RelabelConfig struct {
+	// SourceLabel is "balancer" or "worker".
+	SourceLabel string `yaml:"source_label"`
+	// Regex is matched against the whole label value.
+	Regex string `yaml:"regex"`
+	// Replacement is used by the replace action and may refer to capture
+	// groups of Regex, e.g. "$1".
+	Replacement string `yaml:"replacement"`
+	// Action is one of replace (the default), keep or drop.
+	Action string `yaml:"action"`
+
+	re *regexp.Regexp
+}
+
+type relabelFile struct {
+	RelabelConfigs []RelabelConfig `yaml:"relabel_configs"`
+}
+
+// LoadRelabelConfigs reads and validates the relabel_configs of a YAML file.
+func LoadRelabelConfigs(path string) ([]RelabelConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f relabelFile
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for i := range f.RelabelConfigs {
+		rc := &f.RelabelConfigs[i]
+		if rc.SourceLabel != "balancer" && rc.SourceLabel != "worker" {
+			return nil, fmt.Errorf("%s: relabel config %d: source_label must be balancer or worker, got %q", path, i, rc.SourceLabel)
+		}
+		switch rc.Action {
+		case "":
+			rc.Action = "replace"
+		case "replace", "keep", "drop":
+		default:
+			return nil, fmt.Errorf("%s: relabel config %d: unknown action %q", path, i, rc.Action)
+		}
+		if rc.re, err = regexp.Compile("^(?:" + rc.Regex + ")$"); err != nil {
+			return nil, fmt.Errorf("%s: relabel config %d: %w", path, i, err)
+		}
+	}
+	return f.RelabelConfigs, nil
+}
+
+// BalancerRelabeler applies relabel configs to proxy balancer and worker
+// names and caps the number of worker series exported per target. It exports
+// apache_exporter_dropped_series_total for the workers it drops.
+type BalancerRelabeler struct {
+	configs    []RelabelConfig
+	maxWorkers int
+	dropped    *prometheus.CounterVec
+}
+
+// NewBalancerRelabeler returns a relabeler applying configs, which must come
+// from LoadRelabelConfigs, and keeping at most maxWorkers workers per scrape.
+// A maxWorkers of 0 means no limit.
+func NewBalancerRelabeler(configs []RelabelConfig, maxWorkers int) *BalancerRelabeler {
+	return &BalancerRelabeler{
+		configs:    configs,
+		maxWorkers: maxWorkers,
+		dropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "exporter_dropped_series_total",
+			Help:      "Number of proxy balancer workers dropped by relabeling, the per-target worker limit or as duplicates after relabeling.",
+		},
+			[]string{"reason"},
+		),
+	}
+}
+
+// Describe implements Prometheus.Collector.
+func (r *BalancerRelabeler) Describe(ch chan<- *prometheus.Desc) {
+	r.dropped.Describe(ch)
+}
+
+// Collect implements Prometheus.Collector.
+func (r *BalancerRelabeler) Collect(ch chan<- prometheus.Metric) {
+	r.dropped.Collect(ch)
+}
+
+// apply runs the configs for label over value, returning the new value and
+// whether it is kept.
+func (r *BalancerRelabeler) apply(label, value string) (string, bool) {
+	for _, rc := range r.configs {
+		if rc.SourceLabel != label {
+			continue
+		}
+		matches := rc.re.FindStringSubmatchIndex(value)
+		switch rc.Action {
+		case "keep":
+			if matches == nil {
+				return "", false
+			}
+		case "drop":
+			if matches != nil {
+				return "", false
+			}
+		case "replace":
+			if matches != nil {
+				value = string(rc.re.ExpandString(nil, rc.Replacement, value, matches))
+			}
+		}
+	}
+	return value, true
+}
+
+// balancerScrape tracks the workers admitted during one scrape.
+type balancerScrape struct {
+	r    *BalancerRelabeler
+	seen map[[2]string]bool
+}
+
+// newScrape starts a scrape. A nil relabeler passes names through unchanged
+// but still drops duplicate workers.
+func (r *BalancerRelabeler) newScrape() *balancerScrape {
+	return &balancerScrape{r: r, seen: make(map[[2]string]bool)}
+}
+
+// balancer returns the relabeled balancer name and whether it is kept.
+func (s *balancerScrape) balancer(name string) (string, bool) {
+	if s.r == nil {
+		return name, true
+	}
+	return s.r.apply("balancer", name)
+}
+
+// worker returns the relabeled balancer and worker names and whether the
+// worker is exported.
+func (s *balancerScrape) worker(balancer, worker string) (string, string, bool) {
+	if s.r != nil {
+		var keep bool
+		if balancer, keep = s.r.apply("balancer", balancer); !keep {
+			s.drop("relabel")
+			return "", "", false
+		}
+		if worker, keep = s.r.apply("worker", worker); !keep {
+			s.drop("relabel")
+			return "", "", false
+		}
+	}
+
+	key := [2]string{balancer, worker}
+	if s.seen[key] {
+		s.drop("duplicate")
+		return "", "", false
+	}
+	if s.r != nil && s.r.maxWorkers > 0 && len(s.seen) >= s.r.maxWorkers {
+		s.drop("limit")
+		return "", "", false
+	}
+	s.seen[key] = true
+	return balancer, worker, true
+}
+
+func (s *balancerScrape) drop(reason string) {
+	if s.r != nil {
+		s.r.dropped.WithLabelValues(reason).Inc()
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func loadTestRelabelConfigs(t *testing.T, yaml string) []RelabelConfig {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "relabel.yml")
+	if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	configs, err := LoadRelabelConfigs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return configs
+}
+
+func TestBalancerRelabeling(t *testing.T) {
+	configs := loadTestRelabelConfigs(t, `
+relabel_configs:
+  - source_label: balancer
+    regex: balancer://(.*)
+    replacement: $1
+  - source_label: worker
+    regex: http://app-03:8080
+    action: drop
+  - source_label: worker
+    regex: http://([^:]+):\d+
+    replacement: $1
+`)
+	relabeler := NewBalancerRelabeler(configs, 2)
+	e := newTestExporter(t, proxyFlagsStatus, &Config{BalancerRelabeler: relabeler})
+
+	expected := `
+# HELP apache_proxy_balancer_status Apache Proxy Balancer Statuses
+# TYPE apache_proxy_balancer_status gauge
+apache_proxy_balancer_status{balancer="app",status="Init Ok",worker="app-01"} 1
+apache_proxy_balancer_status{balancer="app",status="Init Dis Drn HcFl",worker="app-02"} 1
+`
+	if err := testutil.CollectAndCompare(e, strings.NewReader(expected), "apache_proxy_balancer_status"); err != nil {
+		t.Error(err)
+	}
+
+	expected = `
+# HELP apache_exporter_dropped_series_total Number of proxy balancer workers dropped by relabeling, the per-target worker limit or as duplicates after relabeling.
+# TYPE apache_exporter_dropped_series_total counter
+apache_exporter_dropped_series_total{reason="limit"} 1
+apache_exporter_dropped_series_total{reason="relabel"} 1
+`
+	if err := testutil.CollectAndCompare(relabeler, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLoadRelabelConfigsInvalid(t *testing.T) {
+	for name, yaml := range map[string]string{
+		"source_label": "relabel_configs:\n  - source_label: member\n    regex: .*\n",
+		"action":       "relabel_configs:\n  - source_label: worker\n    action: hashmod\n",
+		"regex":        "relabel_configs:\n  - source_label: worker\n    regex: '('\n",
+		"unknown key":  "relabel_configs:\n  - source_label: worker\n    target_label: x\n",
+	} {
+		path := filepath.Join(t.TempDir(), "relabel.yml")
+		if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadRelabelConfigs(path); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Layout of the Apache 2.4 scoreboard (include/scoreboard.h) on LP64
+// platforms. The shared segment holds one global_score, followed by
+// ServerLimit process_score records and ServerLimit*ThreadLimit
+// worker_score records.
+//
+// global_score has been the same throughout 2.4: three ints, padding to
+// align the apr_time_t restart_time, and nothing after it. Apache 2.2 had a
+// different layout and is not supported.
+const (
+	globalServerLimit       = 0
+	globalThreadLimit       = 4
+	globalRunningGeneration = 8
+	globalRestartTime       = 16
+	globalScoreSize         = globalRestartTime + 8
+
+	processPID             = 0
+	processGeneration      = 4
+	processQuiescing       = 8
+	processConnections     = 12
+	processWriteCompletion = 16
+	processLingeringClose  = 20
+	processKeepAlive       = 24
+
+	workerStatus       = 20
+	workerAccessCount  = 32
+	workerBytesServed  = 40
+	aprSizeTHeaderSize = 8
+)
+
+// process_score and worker_score have grown fields over the 2.4 series
+// (wait_io, duration and client64), so the known sizes are tried in turn
+// when working out the layout of a segment.
+var (
+	processScoreSizes = []int{36, 40}
+	workerScoreSizes  = []int{264, 272, 336}
+)
+
+// Worker states as defined in scoreboard.h, mapped to the characters
+// mod_status uses in its Scoreboard line.
+const (
+	serverDead     = 0
+	serverStarting = 1
+	serverReady    = 2
+	serverIdleKill = 10
+)
+
+var scoreboardStatusChars = []byte{'.', 'S', '_', 'R', 'W', 'K', 'L', 'D', 'C', 'G', 'I'}
+
+// scoreboardImage is the decoded content of an Apache scoreboard segment.
+type scoreboardImage struct {
+	serverLimit       int
+	threadLimit       int
+	runningGeneration int
+	restartTime       time.Time
+
+	scoreboard     string
+	busyWorkers    int
+	idleWorkers    int
+	processes      int
+	stopping       int
+	connsTotal     uint64
+	connsWriting   uint64
+	connsKeepAlive uint64
+	connsClosing   uint64
+	accessesTotal  uint64
+	bytesServed    uint64
+}
+
+// decodeScoreboard decodes the usable part of an Apache scoreboard segment.
+func decodeScoreboard(data []byte, order binary.ByteOrder) (*scoreboardImage, error) {
+	if len(data) < globalScoreSize {
+		return nil, fmt.Errorf("scoreboard too short: %d bytes", len(data))
+	}
+
+	sb := &scoreboardImage{
+		serverLimit:       int(int32(order.Uint32(data[globalServerLimit:]))),
+		threadLimit:       int(int32(order.Uint32(data[globalThreadLimit:]))),
+		runningGeneration: int(int32(order.Uint32(data[globalRunningGeneration:]))),
+		restartTime:       time.UnixMicro(int64(order.Uint64(data[globalRestartTime:]))),
+	}
+	if sb.serverLimit <= 0 || sb.threadLimit <= 0 {
+		return nil, fmt.Errorf("invalid scoreboard limits: ServerLimit %d, ThreadLimit %d", sb.serverLimit, sb.threadLimit)
+	}
+
+	slots := sb.serverLimit * sb.threadLimit
+	processSize, workerSize := 0, 0
+	for _, ps := range processScoreSizes {
+		for _, ws := range workerScoreSizes {
+			if len(data) == globalScoreSize+ps*sb.serverLimit+ws*slots {
+				processSize, workerSize = ps, ws
+			}
+		}
+	}
+	if processSize == 0 {
+		return nil, fmt.Errorf("unrecognised scoreboard layout: %d bytes for ServerLimit %d, ThreadLimit %d", len(data), sb.serverLimit, sb.threadLimit)
+	}
+
+	processes := data[globalScoreSize:]
+	workers := processes[processSize*sb.serverLimit:]
+	board := make([]byte, 0, slots)
+
+	for i := 0; i < sb.serverLimit; i++ {
+		ps := processes[i*processSize:]
+		pid := int32(order.Uint32(ps[processPID:]))
+		quiescing := ps[processQuiescing] != 0
+		generation := int(int32(order.Uint32(ps[processGeneration:])))
+
+		if pid != 0 {
+			sb.processes++
+			if quiescing {
+				sb.stopping++
+			}
+			sb.connsTotal += uint64(order.Uint32(ps[processConnections:]))
+			sb.connsWriting += uint64(order.Uint32(ps[processWriteCompletion:]))
+			sb.connsKeepAlive += uint64(order.Uint32(ps[processKeepAlive:]))
+			sb.connsClosing += uint64(order.Uint32(ps[processLingeringClose:]))
+		}
+
+		for j := 0; j < sb.threadLimit; j++ {
+			ws := workers[(i*sb.threadLimit+j)*workerSize:]
+			status := int(ws[workerStatus])
+			if status < len(scoreboardStatusChars) {
+				board = append(board, scoreboardStatusChars[status])
+			} else {
+				board = append(board, '?')
+			}
+
+			accessCount := order.Uint64(ws[workerAccessCount:])
+			if accessCount != 0 || (status != serverReady && status != serverDead) {
+				sb.accessesTotal += accessCount
+				sb.bytesServed += order.Uint64(ws[workerBytesServed:])
+			}
+
+			// Same accounting as mod_status: only live, non-quiescing
+			// processes contribute to the busy and idle counts.
+			if pid == 0 || quiescing {
+				continue
+			}
+			switch status {
+			case serverReady:
+				if generation == sb.runningGeneration {
+					sb.idleWorkers++
+				}
+			case serverDead, serverStarting, serverIdleKill:
+			default:
+				sb.busyWorkers++
+			}
+		}
+	}
+	sb.scoreboard = string(board)
+
+	return sb, nil
+}
+
+// ScoreboardFileCollector reads the Apache scoreboard straight from the shared
+// memory backing ScoreBoardFile, so metrics are available even when every
+// worker is too busy to answer a server-status request.
+type ScoreboardFileCollector struct {
+	path string
+	// order is the byte order of the segment, that of the host Apache
+	// runs on.
+	order  binary.ByteOrder
+	mutex  sync.Mutex
+	logger *slog.Logger
+
+	up             *prometheus.Desc
+	scrapeFailures prometheus.Counter
+	generation     *prometheus.Desc
+	accessesTotal  *prometheus.Desc
+	kBytesTotal    *prometheus.Desc
+	uptime         *prometheus.Desc
+	workers        *prometheus.Desc
+	processes      *prometheus.Desc
+	connections    *prometheus.Desc
+	scoreboard     *prometheus.Desc
+}
+
+func NewScoreboardFileCollector(logger *slog.Logger, config *Config) *ScoreboardFileCollector {
+	return &ScoreboardFileCollector{
+		path:   config.ScoreboardFile,
+		order:  binary.NativeEndian,
+		logger: logger,
+		up: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "up"),
+			"Could the apache scoreboard be read",
+			nil,
+			nil),
+		scrapeFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "exporter_scrape_failures_total",
+			Help:      "Number of errors while scraping apache.",
+		}),
+		generation: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "generation"),
+			"Apache restart generation",
+			[]string{"type"}, nil,
+		),
+		accessesTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "accesses_total"),
+			"Current total apache accesses (*)",
+			nil,
+			nil),
+		kBytesTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "sent_kilobytes_total"),
+			"Current total kbytes sent (*)",
+			nil,
+			nil),
+		uptime: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "uptime_seconds_total"),
+			"Current uptime in seconds (*)",
+			nil,
+			nil),
+		workers: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "workers"),
+			"Apache worker statuses",
+			[]string{"state"}, nil,
+		),
+		processes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "processes"),
+			"Apache process count",
+			[]string{"state"}, nil,
+		),
+		connections: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "connections"),
+			"Apache connection statuses",
+			[]string{"state"}, nil,
+		),
+		scoreboard: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "scoreboard"),
+			"Apache scoreboard statuses",
+			[]string{"state"}, nil,
+		),
+	}
+}
+
+// Describe implements Prometheus.Collector.
+func (c *ScoreboardFileCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up
+	c.scrapeFailures.Describe(ch)
+	ch <- c.generation
+	ch <- c.accessesTotal
+	ch <- c.kBytesTotal
+	ch <- c.uptime
+	ch <- c.workers
+	ch <- c.processes
+	ch <- c.connections
+	ch <- c.scoreboard
+}
+
+// readScoreboardSegment returns the usable part of the shared memory segment
+// named by path. APR either maps the file itself, prefixing the segment with
+// its size, or only writes the requested size to the file and keys a SysV
+// segment off it with ftok(3).
+func readScoreboardSegment(path string, order binary.ByteOrder) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(data) == aprSizeTHeaderSize:
+		return attachSysvScoreboard(path, order.Uint64(data))
+	case len(data) > aprSizeTHeaderSize:
+		return data[aprSizeTHeaderSize:], nil
+	default:
+		return nil, fmt.Errorf("%s is not an apache scoreboard file", path)
+	}
+}
+
+func (c *ScoreboardFileCollector) collect(ch chan<- prometheus.Metric) error {
+	data, err := readScoreboardSegment(c.path, c.order)
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+		return fmt.Errorf("error reading scoreboard: %w", err)
+	}
+
+	sb, err := decodeScoreboard(data, c.order)
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 1)
+
+	ch <- prometheus.MustNewConstMetric(c.generation, prometheus.GaugeValue, float64(sb.runningGeneration), "mpm")
+	ch <- prometheus.MustNewConstMetric(c.accessesTotal, prometheus.CounterValue, float64(sb.accessesTotal))
+	ch <- prometheus.MustNewConstMetric(c.kBytesTotal, prometheus.CounterValue, float64(sb.bytesServed>>10))
+	ch <- prometheus.MustNewConstMetric(c.uptime, prometheus.CounterValue, time.Since(sb.restartTime).Truncate(time.Second).Seconds())
+
+	ch <- prometheus.MustNewConstMetric(c.workers, prometheus.GaugeValue, float64(sb.busyWorkers), "busy")
+	ch <- prometheus.MustNewConstMetric(c.workers, prometheus.GaugeValue, float64(sb.idleWorkers), "idle")
+	ch <- prometheus.MustNewConstMetric(c.processes, prometheus.GaugeValue, float64(sb.processes), "all")
+	ch <- prometheus.MustNewConstMetric(c.processes, prometheus.GaugeValue, float64(sb.stopping), "stopping")
+	ch <- prometheus.MustNewConstMetric(c.connections, prometheus.GaugeValue, float64(sb.connsTotal), "total")
+	ch <- prometheus.MustNewConstMetric(c.connections, prometheus.GaugeValue, float64(sb.connsWriting), "writing")
+	ch <- prometheus.MustNewConstMetric(c.connections, prometheus.GaugeValue, float64(sb.connsKeepAlive), "keepalive")
+	ch <- prometheus.MustNewConstMetric(c.connections, prometheus.GaugeValue, float64(sb.connsClosing), "closing")
+
+	counts := make(map[string]float64, len(scoreboardLabelMap))
+	for _, v := range scoreboardLabelMap {
+		counts[v] = 0
+	}
+	for _, worker_status := range sb.scoreboard {
+		s := string(worker_status)
+		label, ok := scoreboardLabelMap[s]
+		if !ok {
+			label = s
+		}
+		counts[label]++
+	}
+	for label, v := range counts {
+		ch <- prometheus.MustNewConstMetric(c.scoreboard, prometheus.GaugeValue, v, label)
+	}
+
+	return nil
+}
+
+// Collect implements Prometheus.Collector.
+func (c *ScoreboardFileCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.collect(ch); err != nil {
+		c.logger.Error("Error reading Apache scoreboard", "err", err)
+		c.scrapeFailures.Inc()
+		c.scrapeFailures.Collect(ch)
+	}
+}
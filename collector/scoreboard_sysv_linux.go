@@ -0,0 +1,51 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+//go:build linux
+
+package collector
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// ftok mirrors glibc's ftok(3), which APR uses with a project id of 1 to key
+// the scoreboard segment off ScoreBoardFile.
+func ftok(path string, id byte) (int, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return 0, err
+	}
+	return int(uint32(st.Ino&0xffff) | uint32(st.Dev&0xff)<<16 | uint32(id)<<24), nil
+}
+
+// attachSysvScoreboard copies size bytes out of the SysV shared memory
+// segment belonging to the scoreboard file at path.
+func attachSysvScoreboard(path string, size uint64) ([]byte, error) {
+	key, err := ftok(path, 1)
+	if err != nil {
+		return nil, err
+	}
+	id, err := unix.SysvShmGet(key, 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("error looking up shared memory segment for %s: %w", path, err)
+	}
+	segment, err := unix.SysvShmAttach(id, 0, unix.SHM_RDONLY)
+	if err != nil {
+		return nil, fmt.Errorf("error attaching shared memory segment for %s: %w", path, err)
+	}
+	defer unix.SysvShmDetach(segment)
+
+	if uint64(len(segment)) < size {
+		return nil, fmt.Errorf("shared memory segment for %s is %d bytes, expected %d", path, len(segment), size)
+	}
+	data := make([]byte, size)
+	copy(data, segment)
+	return data, nil
+}
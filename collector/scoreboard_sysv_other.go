@@ -0,0 +1,18 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+//go:build !linux
+
+package collector
+
+import (
+	"errors"
+)
+
+func attachSysvScoreboard(path string, size uint64) ([]byte, error) {
+	return nil, errors.New("SysV shared memory scoreboards are only supported on Linux")
+}
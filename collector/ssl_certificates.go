@@ -0,0 +1,199 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// certificateFile is a certificate file and the vhost that references it.
+type certificateFile struct {
+	vhost string
+	path  string
+}
+
+// configCertificateFiles returns the SSLCertificateFile and
+// SSLCertificateChainFile paths referenced by an httpd config, labelled with
+// the ServerName of their vhost. Relative paths are resolved against
+// ServerRoot.
+func configCertificateFiles(configFile string, directives []configDirective) []certificateFile {
+	serverRoot := filepath.Dir(configFile)
+	serverNames := make(map[*configVirtualHost]string)
+	for _, d := range directives {
+		if d.vhost != nil && strings.EqualFold(d.name, "ServerName") && len(d.args) > 0 {
+			serverNames[d.vhost] = strings.Split(d.args[0], ":")[0]
+		}
+	}
+
+	var files []certificateFile
+	for _, d := range directives {
+		switch strings.ToLower(d.name) {
+		case "serverroot":
+			if len(d.args) == 1 {
+				serverRoot = d.args[0]
+			}
+		case "sslcertificatefile", "sslcertificatechainfile":
+			if len(d.args) != 1 {
+				continue
+			}
+			path := d.args[0]
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(serverRoot, path)
+			}
+			vhost := ""
+			if d.vhost != nil {
+				vhost = serverNames[d.vhost]
+				if vhost == "" {
+					vhost = strings.Join(d.vhost.addrs, " ")
+				}
+			}
+			files = append(files, certificateFile{vhost: vhost, path: path})
+		}
+	}
+	return files
+}
+
+// readCertificates returns all certificates in a PEM file, skipping keys and
+// other blocks.
+func readCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%s: no certificates found", path)
+	}
+	return certs, nil
+}
+
+// SSLCertificateCollector exports the expiry of the certificates Apache is
+// configured with, including intermediates from chain files.
+type SSLCertificateCollector struct {
+	configFile string
+	glob       string
+	mutex      sync.Mutex
+	logger     *slog.Logger
+
+	expiry   *prometheus.Desc
+	readable *prometheus.Desc
+}
+
+func NewSSLCertificateCollector(logger *slog.Logger, config *Config) *SSLCertificateCollector {
+	return &SSLCertificateCollector{
+		configFile: config.HTTPDConfigFile,
+		glob:       config.SSLCertificateGlob,
+		logger:     logger,
+		expiry: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "ssl_certificate", "expiry_timestamp_seconds"),
+			"Expiry of certificates referenced by Apache, in seconds since the epoch",
+			[]string{"vhost", "subject", "issuer", "serial"}, nil,
+		),
+		readable: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "ssl_certificate", "file_readable"),
+			"Whether a certificate file referenced by Apache could be read",
+			[]string{"file"}, nil,
+		),
+	}
+}
+
+// Describe implements Prometheus.Collector.
+func (c *SSLCertificateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.expiry
+	ch <- c.readable
+}
+
+// certificateFiles discovers the certificate files to read on this scrape.
+func (c *SSLCertificateCollector) certificateFiles() []certificateFile {
+	var files []certificateFile
+	if c.configFile != "" {
+		directives, err := parseHTTPDConfig(c.configFile)
+		if err != nil {
+			c.logger.Error("Error parsing Apache config", "file", c.configFile, "err", err)
+		}
+		files = append(files, configCertificateFiles(c.configFile, directives)...)
+	}
+	if c.glob != "" {
+		matches, err := filepath.Glob(c.glob)
+		if err != nil {
+			c.logger.Error("Invalid certificate glob", "glob", c.glob, "err", err)
+		}
+		sort.Strings(matches)
+		for _, path := range matches {
+			files = append(files, certificateFile{path: path})
+		}
+	}
+	return files
+}
+
+// Collect implements Prometheus.Collector.
+func (c *SSLCertificateCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	type series struct{ vhost, subject, issuer, serial string }
+	seen := make(map[series]bool)
+	readable := make(map[string]bool)
+
+	for _, f := range c.certificateFiles() {
+		certs, err := readCertificates(f.path)
+		if _, ok := readable[f.path]; !ok {
+			readable[f.path] = err == nil
+			if err != nil {
+				c.logger.Error("Error reading certificate", "file", f.path, "err", err)
+			}
+		}
+		for _, cert := range certs {
+			s := series{
+				vhost:   f.vhost,
+				subject: cert.Subject.String(),
+				issuer:  cert.Issuer.String(),
+				serial:  strings.ToUpper(cert.SerialNumber.Text(16)),
+			}
+			if seen[s] {
+				continue
+			}
+			seen[s] = true
+			ch <- prometheus.MustNewConstMetric(c.expiry, prometheus.GaugeValue, float64(cert.NotAfter.Unix()), s.vhost, s.subject, s.issuer, s.serial)
+		}
+	}
+
+	for path, ok := range readable {
+		val := 0.0
+		if ok {
+			val = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.readable, prometheus.GaugeValue, val, path)
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+)
+
+func TestSSLCertificatesFromConfig(t *testing.T) {
+	c := NewSSLCertificateCollector(promslog.NewNopLogger(), &Config{HTTPDConfigFile: "testdata/httpd/conf/httpd.conf"})
+
+	expected := `
+# HELP apache_ssl_certificate_expiry_timestamp_seconds Expiry of certificates referenced by Apache, in seconds since the epoch
+# TYPE apache_ssl_certificate_expiry_timestamp_seconds gauge
+apache_ssl_certificate_expiry_timestamp_seconds{issuer="CN=Example Intermediate CA,O=Example",serial="BEEF",subject="CN=secure.example.com,O=Example",vhost="secure.example.com"} 1.9065024e+09
+apache_ssl_certificate_expiry_timestamp_seconds{issuer="CN=Example Root CA,O=Example",serial="1000",subject="CN=Example Intermediate CA,O=Example",vhost="secure.example.com"} 2.0512224e+09
+# HELP apache_ssl_certificate_file_readable Whether a certificate file referenced by Apache could be read
+# TYPE apache_ssl_certificate_file_readable gauge
+apache_ssl_certificate_file_readable{file="testdata/certs/secure.pem"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSSLCertificatesFromGlob(t *testing.T) {
+	c := NewSSLCertificateCollector(promslog.NewNopLogger(), &Config{SSLCertificateGlob: "testdata/certs/*.pem"})
+
+	if n := testutil.CollectAndCount(c, "apache_ssl_certificate_expiry_timestamp_seconds"); n != 2 {
+		t.Errorf("expected 2 certificates, got %d", n)
+	}
+}
@@ -0,0 +1,345 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Matches the request column mod_http2 sets for its connections, e.g.
+// "busy, streams: 3/41/38/2/1 (open/recv/resp/push/rst)".
+var reHTTP2Streams = regexp.MustCompile(`^([^,]*), streams: (\d+)/(\d+)/(\d+)/(\d+)/(\d+) \(open/recv/resp/push/rst\)`)
+
+// Matches the heading mod_fcgid puts above each process class table, e.g.
+// "Process: php-cgi (/usr/bin/php-cgi)".
+var reFcgidProcessClass = regexp.MustCompile(`Process: \S+ \((.*?)\)\s*$`)
+
+// statusWorker is a row of the worker table server-status shows with
+// ExtendedStatus On.
+type statusWorker struct {
+	srv      string
+	pid      string
+	mode     string
+	seconds  float64
+	client   string
+	protocol string
+	vhost    string
+	request  string
+}
+
+// fcgidProcess is a FastCGI process listed by mod_fcgid.
+type fcgidProcess struct {
+	command  string
+	pid      string
+	accesses float64
+	state    string
+}
+
+// statusPage is the part of the HTML server-status page that ?auto omits.
+type statusPage struct {
+	// extended is set if the page has the worker table, which Apache only
+	// shows with ExtendedStatus On.
+	extended bool
+	workers  []statusWorker
+	fcgid    []fcgidProcess
+}
+
+var errNoWorkerTable = errors.New("no worker table on server-status page, is ExtendedStatus On?")
+
+// statusPageURI returns the HTML server-status page for a ?auto scrape URI.
+func statusPageURI(scrapeURI string) (string, error) {
+	u, err := url.Parse(scrapeURI)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Del("auto")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// parseStatusPage reads the HTML server-status page.
+func parseStatusPage(data []byte) (*statusPage, error) {
+	tables, err := parseHTMLTables(data)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &statusPage{}
+	for _, t := range tables {
+		if t.hasColumn("Pid") && t.hasColumn("Accesses") {
+			processes, err := parseFcgidTable(&t)
+			if err != nil {
+				return nil, err
+			}
+			page.fcgid = append(page.fcgid, processes...)
+			continue
+		}
+		if !t.hasColumn("Srv") || !t.hasColumn("Request") {
+			continue
+		}
+		page.extended = true
+		for _, row := range t.rows {
+			w := statusWorker{
+				srv:      t.column(row, "Srv"),
+				pid:      t.column(row, "PID"),
+				mode:     t.column(row, "M"),
+				client:   t.column(row, "Client"),
+				protocol: t.column(row, "Protocol"),
+				vhost:    t.column(row, "VHost"),
+				request:  t.column(row, "Request"),
+			}
+			if ss := t.column(row, "SS"); ss != "" {
+				if w.seconds, err = strconv.ParseFloat(ss, 64); err != nil {
+					return nil, fmt.Errorf("worker %s: invalid SS: %w", w.srv, err)
+				}
+			}
+			page.workers = append(page.workers, w)
+		}
+	}
+	return page, nil
+}
+
+// parseFcgidTable reads a process class table of the mod_fcgid section.
+func parseFcgidTable(t *htmlTable) ([]fcgidProcess, error) {
+	m := reFcgidProcessClass.FindStringSubmatch(t.before)
+	if m == nil {
+		return nil, fmt.Errorf("mod_fcgid process table without process class: %q", t.before)
+	}
+
+	processes := make([]fcgidProcess, 0, len(t.rows))
+	for _, row := range t.rows {
+		p := fcgidProcess{
+			command: m[1],
+			pid:     t.column(row, "Pid"),
+			state:   fcgidState(t.column(row, "State")),
+		}
+		var err error
+		if p.accesses, err = strconv.ParseFloat(t.column(row, "Accesses"), 64); err != nil {
+			return nil, fmt.Errorf("mod_fcgid process %s: invalid Accesses: %w", p.pid, err)
+		}
+		processes = append(processes, p)
+	}
+	return processes, nil
+}
+
+// fcgidState maps the state mod_fcgid shows for a process to ready, working
+// or exiting, dropping the exit reason, e.g. "Exiting(idle timeout)".
+func fcgidState(s string) string {
+	s, _, _ = strings.Cut(s, "(")
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// busy reports whether the worker is serving a connection, rather than
+// waiting for one or being an open slot.
+func (w *statusWorker) busy() bool {
+	return w.mode != "_" && w.mode != "." && w.mode != ""
+}
+
+// StatusPageCollector scrapes the HTML server-status page for what modules
+// add to it and the ?auto output does not include: HTTP/2 connections, the
+// protocol of each connection and mod_fcgid processes.
+type StatusPageCollector struct {
+	URI    string
+	mutex  sync.Mutex
+	http   *httpClient
+	logger *slog.Logger
+
+	up                 *prometheus.Desc
+	protocolWorkers    *prometheus.Desc
+	http2Connections   *prometheus.Desc
+	http2ActiveStreams *prometheus.Desc
+	http2Streams       *prometheus.Desc
+	http2Responses     *prometheus.Desc
+	http2Pushes        *prometheus.Desc
+	http2Resets        *prometheus.Desc
+	fcgidProcesses     *prometheus.Desc
+	fcgidRequests      *prometheus.Desc
+}
+
+func NewStatusPageCollector(logger *slog.Logger, config *Config) (*StatusPageCollector, error) {
+	uri := config.StatusPageURI
+	if uri == "" {
+		var err error
+		if uri, err = statusPageURI(config.ScrapeURI); err != nil {
+			return nil, err
+		}
+	}
+	return &StatusPageCollector{
+		URI:    uri,
+		http:   newHTTPClient(config),
+		logger: logger,
+		up: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "status_page", "up"),
+			"Could the apache server-status HTML page be reached and parsed",
+			nil,
+			nil),
+		protocolWorkers: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "protocol_workers"),
+			"Busy workers by protocol of their connection (http/1.1, h2, h2c)",
+			[]string{"protocol"}, nil,
+		),
+		http2Connections: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "http2", "connections"),
+			"HTTP/2 connections by mod_http2 session state",
+			[]string{"state"}, nil,
+		),
+		http2ActiveStreams: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "http2", "active_streams"),
+			"Open streams on current HTTP/2 connections",
+			nil,
+			nil),
+		http2Streams: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "http2", "streams"),
+			"Streams received over the lifetime of current HTTP/2 connections",
+			nil,
+			nil),
+		http2Responses: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "http2", "responses"),
+			"Responses submitted over the lifetime of current HTTP/2 connections",
+			nil,
+			nil),
+		http2Pushes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "http2", "pushes"),
+			"Server pushes submitted over the lifetime of current HTTP/2 connections",
+			nil,
+			nil),
+		http2Resets: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "http2", "stream_resets"),
+			"Streams and pushes reset over the lifetime of current HTTP/2 connections",
+			nil,
+			nil),
+		fcgidProcesses: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "fcgid", "processes"),
+			"mod_fcgid FastCGI processes by process class command and state (ready, working, exiting)",
+			[]string{"command", "state"}, nil,
+		),
+		fcgidRequests: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "fcgid", "process_requests"),
+			"Requests handled by the current mod_fcgid FastCGI processes of a process class",
+			[]string{"command"}, nil,
+		),
+	}, nil
+}
+
+// Describe implements Prometheus.Collector.
+func (c *StatusPageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up
+	ch <- c.protocolWorkers
+	ch <- c.http2Connections
+	ch <- c.http2ActiveStreams
+	ch <- c.http2Streams
+	ch <- c.http2Responses
+	ch <- c.http2Pushes
+	ch <- c.http2Resets
+	ch <- c.fcgidProcesses
+	ch <- c.fcgidRequests
+}
+
+func (c *StatusPageCollector) collectHTTP2(ch chan<- prometheus.Metric, page *statusPage) {
+	var (
+		connections = make(map[string]float64)
+		found       bool
+		// open, recv, resp, push, rst
+		streams [5]float64
+	)
+	for _, w := range page.workers {
+		m := reHTTP2Streams.FindStringSubmatch(w.request)
+		if m == nil {
+			continue
+		}
+		found = true
+		connections[strings.ToLower(strings.TrimSpace(m[1]))]++
+		for i := range streams {
+			val, _ := strconv.ParseFloat(m[i+2], 64)
+			streams[i] += val
+		}
+	}
+	if !found {
+		return
+	}
+
+	for state, n := range connections {
+		ch <- prometheus.MustNewConstMetric(c.http2Connections, prometheus.GaugeValue, n, state)
+	}
+	ch <- prometheus.MustNewConstMetric(c.http2ActiveStreams, prometheus.GaugeValue, streams[0])
+	ch <- prometheus.MustNewConstMetric(c.http2Streams, prometheus.GaugeValue, streams[1])
+	ch <- prometheus.MustNewConstMetric(c.http2Responses, prometheus.GaugeValue, streams[2])
+	ch <- prometheus.MustNewConstMetric(c.http2Pushes, prometheus.GaugeValue, streams[3])
+	ch <- prometheus.MustNewConstMetric(c.http2Resets, prometheus.GaugeValue, streams[4])
+}
+
+func (c *StatusPageCollector) collectFcgid(ch chan<- prometheus.Metric, page *statusPage) {
+	type class struct{ command, state string }
+	processes := make(map[class]float64)
+	requests := make(map[string]float64)
+	for _, p := range page.fcgid {
+		if _, ok := requests[p.command]; !ok {
+			// Report every state of a class, so that a class running
+			// out of ready processes shows up as 0.
+			for _, state := range []string{"ready", "working", "exiting"} {
+				processes[class{p.command, state}] = 0
+			}
+		}
+		processes[class{p.command, p.state}]++
+		requests[p.command] += p.accesses
+	}
+
+	for cl, n := range processes {
+		ch <- prometheus.MustNewConstMetric(c.fcgidProcesses, prometheus.GaugeValue, n, cl.command, cl.state)
+	}
+	for command, n := range requests {
+		ch <- prometheus.MustNewConstMetric(c.fcgidRequests, prometheus.GaugeValue, n, command)
+	}
+}
+
+func (c *StatusPageCollector) collect(ch chan<- prometheus.Metric) error {
+	data, err := c.http.get(c.URI)
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+		return err
+	}
+	page, err := parseStatusPage(data)
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 1)
+
+	protocols := make(map[string]float64)
+	for _, w := range page.workers {
+		if w.protocol != "" && w.busy() {
+			protocols[w.protocol]++
+		}
+	}
+	for protocol, n := range protocols {
+		ch <- prometheus.MustNewConstMetric(c.protocolWorkers, prometheus.GaugeValue, n, protocol)
+	}
+
+	c.collectHTTP2(ch, page)
+	c.collectFcgid(ch, page)
+	return nil
+}
+
+// Collect implements Prometheus.Collector.
+func (c *StatusPageCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.collect(ch); err != nil {
+		c.logger.Error("Error scraping Apache server-status page", "err", err)
+	}
+}
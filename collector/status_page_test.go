@@ -0,0 +1,132 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+)
+
+func newTestStatusPageCollector(t *testing.T, fixture string) *StatusPageCollector {
+	page, err := os.ReadFile(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.RawQuery != "" {
+			http.Error(w, "unexpected query "+r.URL.RawQuery, http.StatusBadRequest)
+			return
+		}
+		w.Write(page)
+	}))
+	t.Cleanup(server.Close)
+
+	c, err := NewStatusPageCollector(promslog.NewNopLogger(), &Config{ScrapeURI: server.URL + "/server-status?auto"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestStatusPageHTTP2(t *testing.T) {
+	c := newTestStatusPageCollector(t, "testdata/server-status.html")
+
+	expected := `
+# HELP apache_http2_active_streams Open streams on current HTTP/2 connections
+# TYPE apache_http2_active_streams gauge
+apache_http2_active_streams 4
+# HELP apache_http2_connections HTTP/2 connections by mod_http2 session state
+# TYPE apache_http2_connections gauge
+apache_http2_connections{state="busy"} 2
+apache_http2_connections{state="wait"} 1
+# HELP apache_http2_pushes Server pushes submitted over the lifetime of current HTTP/2 connections
+# TYPE apache_http2_pushes gauge
+apache_http2_pushes 2
+# HELP apache_http2_responses Responses submitted over the lifetime of current HTTP/2 connections
+# TYPE apache_http2_responses gauge
+apache_http2_responses 59
+# HELP apache_http2_stream_resets Streams and pushes reset over the lifetime of current HTTP/2 connections
+# TYPE apache_http2_stream_resets gauge
+apache_http2_stream_resets 1
+# HELP apache_http2_streams Streams received over the lifetime of current HTTP/2 connections
+# TYPE apache_http2_streams gauge
+apache_http2_streams 63
+# HELP apache_protocol_workers Busy workers by protocol of their connection (http/1.1, h2, h2c)
+# TYPE apache_protocol_workers gauge
+apache_protocol_workers{protocol="h2"} 3
+apache_protocol_workers{protocol="http/1.1"} 2
+# HELP apache_status_page_up Could the apache server-status HTML page be reached and parsed
+# TYPE apache_status_page_up gauge
+apache_status_page_up 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"apache_status_page_up", "apache_protocol_workers", "apache_http2_connections", "apache_http2_active_streams",
+		"apache_http2_streams", "apache_http2_responses", "apache_http2_pushes", "apache_http2_stream_resets",
+	); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStatusPageFcgid(t *testing.T) {
+	c := newTestStatusPageCollector(t, "testdata/server-status.html")
+
+	expected := `
+# HELP apache_fcgid_process_requests Requests handled by the current mod_fcgid FastCGI processes of a process class
+# TYPE apache_fcgid_process_requests gauge
+apache_fcgid_process_requests{command="/srv/wiki/wiki.fcgi"} 301
+apache_fcgid_process_requests{command="/usr/bin/php-cgi"} 6783
+# HELP apache_fcgid_processes mod_fcgid FastCGI processes by process class command and state (ready, working, exiting)
+# TYPE apache_fcgid_processes gauge
+apache_fcgid_processes{command="/srv/wiki/wiki.fcgi",state="exiting"} 0
+apache_fcgid_processes{command="/srv/wiki/wiki.fcgi",state="ready"} 1
+apache_fcgid_processes{command="/srv/wiki/wiki.fcgi",state="working"} 0
+apache_fcgid_processes{command="/usr/bin/php-cgi",state="exiting"} 1
+apache_fcgid_processes{command="/usr/bin/php-cgi",state="ready"} 1
+apache_fcgid_processes{command="/usr/bin/php-cgi",state="working"} 2
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "apache_fcgid_processes", "apache_fcgid_process_requests"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStatusPageWithoutExtendedStatus(t *testing.T) {
+	data, err := os.ReadFile("testdata/server-status-no-extended.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := parseStatusPage(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.extended || len(page.workers) != 0 || len(page.fcgid) != 5 {
+		t.Errorf("got extended %t, %d workers, %d fcgid processes; want false, 0, 5", page.extended, len(page.workers), len(page.fcgid))
+	}
+
+	c := newTestStatusPageCollector(t, "testdata/server-status-no-extended.html")
+
+	expected := `
+# HELP apache_fcgid_process_requests Requests handled by the current mod_fcgid FastCGI processes of a process class
+# TYPE apache_fcgid_process_requests gauge
+apache_fcgid_process_requests{command="/srv/wiki/wiki.fcgi"} 301
+apache_fcgid_process_requests{command="/usr/bin/php-cgi"} 6783
+# HELP apache_status_page_up Could the apache server-status HTML page be reached and parsed
+# TYPE apache_status_page_up gauge
+apache_status_page_up 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"apache_status_page_up", "apache_protocol_workers", "apache_http2_connections", "apache_fcgid_process_requests",
+	); err != nil {
+		t.Error(err)
+	}
+}
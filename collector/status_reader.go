@@ -0,0 +1,140 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// StatusWorker is a row of the worker table of the HTML server-status page.
+type StatusWorker struct {
+	Srv      string
+	PID      string
+	Mode     string
+	Seconds  float64
+	Client   string
+	Protocol string
+	VHost    string
+	Request  string
+	// Busy is set when the worker is serving a connection.
+	Busy bool
+}
+
+// StatusReader fetches the server-status pages of the scrape target with the
+// settings of the exporter, for commands that show them rather than export
+// metrics.
+type StatusReader struct {
+	URI     string
+	PageURI string
+	http    *httpClient
+}
+
+// NewStatusReader returns a reader for config.ScrapeURI and the HTML page
+// config.StatusPageURI, or the scrape URI without ?auto.
+func NewStatusReader(config *Config) (*StatusReader, error) {
+	pageURI := config.StatusPageURI
+	if pageURI == "" {
+		var err error
+		if pageURI, err = statusPageURI(config.ScrapeURI); err != nil {
+			return nil, err
+		}
+	}
+	return &StatusReader{
+		URI:     config.ScrapeURI,
+		PageURI: pageURI,
+		http:    newHTTPClient(config),
+	}, nil
+}
+
+// Auto fetches the server-status?auto page, which Exporter.CollectStatus
+// parses.
+func (r *StatusReader) Auto() ([]byte, error) {
+	return r.http.get(r.URI)
+}
+
+// StatusResponse is the answer of Apache to a request, whatever its status.
+type StatusResponse struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte
+}
+
+// Fetch requests uri without following redirects, for diagnosing why a page
+// cannot be scraped.
+func (r *StatusReader) Fetch(uri string) (*StatusResponse, error) {
+	req, err := r.http.newRequest(uri)
+	if err != nil {
+		return nil, err
+	}
+	client := *r.http.client
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &StatusResponse{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+		Body:       body,
+	}, nil
+}
+
+// Workers fetches the HTML server-status page and returns its worker table,
+// which Apache only shows with ExtendedStatus On.
+func (r *StatusReader) Workers() ([]StatusWorker, error) {
+	data, err := r.http.get(r.PageURI)
+	if err != nil {
+		return nil, err
+	}
+	page, err := parseStatusPage(data)
+	if err != nil {
+		return nil, err
+	}
+	if !page.extended {
+		return nil, errNoWorkerTable
+	}
+	workers := make([]StatusWorker, 0, len(page.workers))
+	for _, w := range page.workers {
+		workers = append(workers, StatusWorker{
+			Srv:      w.srv,
+			PID:      w.pid,
+			Mode:     w.mode,
+			Seconds:  w.seconds,
+			Client:   w.client,
+			Protocol: w.protocol,
+			VHost:    w.vhost,
+			Request:  w.request,
+			Busy:     w.busy(),
+		})
+	}
+	return workers, nil
+}
+
+// StatusScoreboard returns the Scoreboard of a server-status?auto page, one
+// character per worker slot.
+func StatusScoreboard(data []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if key, v := splitkv(scanner.Text()); key == "Scoreboard" {
+			return v
+		}
+	}
+	return ""
+}
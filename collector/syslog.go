@@ -0,0 +1,206 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	// Matches the header of an RFC 5424 syslog message up to the message:
+	// PRI VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA.
+	reSyslog5424 = regexp.MustCompile(`^<\d{1,3}>1 \S+ \S+ \S+ \S+ \S+ (?:-|(?:\[(?:[^\]\\]|\\.)*\])+) ?`)
+	// Matches the header of an RFC 3164 syslog message up to the message:
+	// PRI TIMESTAMP HOSTNAME TAG, the hostname being optional as with
+	// messages sent to a local socket.
+	reSyslog3164 = regexp.MustCompile(`^<\d{1,3}>[A-Z][a-z]{2} [ \d]\d \d\d:\d\d:\d\d (?:\S+ )?[^\s:\[]+(?:\[\d+\])?: ?`)
+	// Matches a bare PRI without any further header.
+	reSyslogPRI = regexp.MustCompile(`^<\d{1,3}>`)
+)
+
+// syslogMessage returns the message of a syslog line, without its header.
+// Lines without a syslog header are returned as is.
+func syslogMessage(line string) string {
+	for _, re := range []*regexp.Regexp{reSyslog5424, reSyslog3164, reSyslogPRI} {
+		if loc := re.FindStringIndex(line); loc != nil {
+			return line[loc[1]:]
+		}
+	}
+	return line
+}
+
+// syslogReceiver receives syslog messages over UDP or TCP and hands their
+// message to handle.
+type syslogReceiver struct {
+	handle func(line string)
+	logger *slog.Logger
+
+	packetConn net.PacketConn
+	listener   net.Listener
+	wg         sync.WaitGroup
+}
+
+// listenSyslog starts receiving syslog messages on address, which is
+// "udp://host:port", "tcp://host:port" or "host:port" for UDP.
+func listenSyslog(logger *slog.Logger, address string, handle func(line string)) (*syslogReceiver, error) {
+	network, addr, ok := strings.Cut(address, "://")
+	if !ok {
+		network, addr = "udp", address
+	}
+
+	r := &syslogReceiver{handle: handle, logger: logger}
+	var err error
+	switch network {
+	case "udp", "udp4", "udp6":
+		if r.packetConn, err = net.ListenPacket(network, addr); err != nil {
+			return nil, err
+		}
+		r.wg.Add(1)
+		go r.receivePackets()
+	case "tcp", "tcp4", "tcp6":
+		if r.listener, err = net.Listen(network, addr); err != nil {
+			return nil, err
+		}
+		r.wg.Add(1)
+		go r.accept()
+	default:
+		return nil, fmt.Errorf("unsupported syslog network %q", network)
+	}
+	return r, nil
+}
+
+// addr returns the address the receiver listens on.
+func (r *syslogReceiver) addr() net.Addr {
+	if r.packetConn != nil {
+		return r.packetConn.LocalAddr()
+	}
+	return r.listener.Addr()
+}
+
+// close stops receiving and waits for the receiver to finish.
+func (r *syslogReceiver) close() {
+	if r.packetConn != nil {
+		r.packetConn.Close()
+	} else {
+		r.listener.Close()
+	}
+	r.wg.Wait()
+}
+
+func (r *syslogReceiver) receivePackets() {
+	defer r.wg.Done()
+	buf := make([]byte, maxSyslogMessageSize)
+	for {
+		n, _, err := r.packetConn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			r.logger.Error("Error receiving syslog message", "err", err)
+			continue
+		}
+		r.handle(syslogMessage(strings.TrimRight(string(buf[:n]), "\r\n")))
+	}
+}
+
+func (r *syslogReceiver) accept() {
+	defer r.wg.Done()
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			r.logger.Error("Error accepting syslog connection", "err", err)
+			continue
+		}
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			defer conn.Close()
+			if err := readSyslogStream(conn, r.handle); err != nil {
+				r.logger.Error("Error reading syslog connection", "remote", conn.RemoteAddr(), "err", err)
+			}
+		}()
+	}
+}
+
+// maxSyslogMessageSize bounds the messages accepted from syslog senders, as
+// the largest UDP datagram does.
+const maxSyslogMessageSize = 64 * 1024
+
+// syslogOctetCount returns the length of an octet counted message at the
+// start of peeked, "<length> <PRI>...", and the size of its "<length> "
+// prefix. ok is false for any other framing, such as a newline delimited
+// message that starts with an IP address.
+func syslogOctetCount(peeked []byte) (n, prefix int, ok bool) {
+	i := 0
+	for i < len(peeked) && peeked[i] >= '0' && peeked[i] <= '9' {
+		i++
+	}
+	if i == 0 || peeked[0] == '0' || i+1 >= len(peeked) || peeked[i] != ' ' || peeked[i+1] != '<' {
+		return 0, 0, false
+	}
+	n, err := strconv.Atoi(string(peeked[:i]))
+	if err != nil {
+		return 0, 0, false
+	}
+	return n, i + 1, true
+}
+
+// readSyslogStream reads syslog messages from a TCP stream until EOF. Both
+// octet counting ("<length> <message>") and newline delimited framing are
+// accepted, as described in RFC 6587. Octet counted messages larger than
+// maxSyslogMessageSize end the stream.
+func readSyslogStream(rd io.Reader, handle func(line string)) error {
+	br := bufio.NewReader(rd)
+	for {
+		// Enough for an oversized count, its space and the < of the PRI.
+		peeked, err := br.Peek(16)
+		if len(peeked) == 0 {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		var msg string
+		if n, prefix, ok := syslogOctetCount(peeked); ok {
+			if n > maxSyslogMessageSize {
+				return fmt.Errorf("octet count %d exceeds the maximum message size of %d", n, maxSyslogMessageSize)
+			}
+			if _, err := br.Discard(prefix); err != nil {
+				return err
+			}
+			buf := make([]byte, n)
+			if _, err := io.ReadFull(br, buf); err != nil {
+				return err
+			}
+			msg = string(buf)
+		} else {
+			msg, err = br.ReadString('\n')
+			if err != nil && err != io.EOF {
+				return err
+			}
+		}
+
+		msg = strings.TrimRight(msg, "\r\n")
+		if msg != "" {
+			handle(syslogMessage(msg))
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/promslog"
+)
+
+const testAccessLine = `192.0.2.1 - - [19/Oct/2026:10:15:02 +0000] "GET / HTTP/1.1" 200 512`
+
+func TestSyslogMessage(t *testing.T) {
+	for _, in := range []string{
+		testAccessLine,
+		"<190>Oct 19 10:15:02 web-01 httpd[4211]: " + testAccessLine,
+		"<190>Oct  9 10:15:02 httpd: " + testAccessLine,
+		"<190>1 2026-10-19T10:15:02.123Z web-01 httpd 4211 - - " + testAccessLine,
+		`<190>1 2026-10-19T10:15:02Z web-01 httpd - access [meta sequenceId="1"] ` + testAccessLine,
+		"<190>" + testAccessLine,
+	} {
+		if got := syslogMessage(in); got != testAccessLine {
+			t.Errorf("%q: got message %q", in, got)
+		}
+	}
+}
+
+func TestReadSyslogStream(t *testing.T) {
+	stream := "<190>Oct 19 10:15:02 web-01 httpd: one\n" +
+		"38 <190>Oct 19 10:15:02 web-01 httpd: two\n" +
+		"192.0.2.10 - - [19/Oct/2026:10:15:02 +0000] \"GET / HTTP/1.1\" 200 512\n" +
+		"<190>Oct 19 10:15:02 web-01 httpd: three"
+	var lines []string
+	if err := readSyslogStream(strings.NewReader(stream), func(line string) { lines = append(lines, line) }); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"one", "two", `192.0.2.10 - - [19/Oct/2026:10:15:02 +0000] "GET / HTTP/1.1" 200 512`, "three"}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected %q, got %q", expected, lines)
+	}
+
+	// An octet count larger than any message is not allocated.
+	if err := readSyslogStream(strings.NewReader("99999999999 <190>x"), func(string) {}); err == nil {
+		t.Error("expected an error for an oversized octet count")
+	}
+}
+
+func TestSyslogReceiver(t *testing.T) {
+	for _, network := range []string{"udp", "tcp"} {
+		var (
+			mutex sync.Mutex
+			lines []string
+		)
+		r, err := listenSyslog(promslog.NewNopLogger(), network+"://127.0.0.1:0", func(line string) {
+			mutex.Lock()
+			lines = append(lines, line)
+			mutex.Unlock()
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		conn, err := net.Dial(network, r.addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Write([]byte("<190>Oct 19 10:15:02 web-01 httpd: " + testAccessLine + "\n")); err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			mutex.Lock()
+			n := len(lines)
+			mutex.Unlock()
+			if n > 0 || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		r.close()
+
+		if expected := []string{testAccessLine}; !reflect.DeepEqual(lines, expected) {
+			t.Errorf("%s: expected %q, got %q", network, expected, lines)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// tailPollInterval is how often tailed files are checked for new lines.
+const tailPollInterval = time.Second
+
+// tailer follows a log file like tail -F, calling handle for every complete
+// line appended to it. It polls the file rather than relying on inotify, so
+// it works the same on every platform and on network filesystems.
+//
+// When the path is replaced by a new file, e.g. by logrotate or rotatelogs,
+// the rest of the old file is read before switching to the new one, which is
+// read from its start. When the file shrinks, e.g. after copytruncate, it is
+// read again from its start.
+type tailer struct {
+	path   string
+	handle func(line string)
+	logger *slog.Logger
+
+	file    *os.File
+	reader  *bufio.Reader
+	offset  int64
+	partial string
+	// fromStart is whether the next file opened is read from its start.
+	// Only the file found when tailing starts is read from its end.
+	fromStart bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newTailer returns a tailer for path that starts reading at the end of the
+// current file. It does nothing until run or poll is called.
+func newTailer(logger *slog.Logger, path string, handle func(line string)) *tailer {
+	return &tailer{
+		path:   path,
+		handle: handle,
+		logger: logger,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// run polls the file until close is called.
+func (t *tailer) run() {
+	defer close(t.done)
+	ticker := time.NewTicker(tailPollInterval)
+	defer ticker.Stop()
+	for {
+		t.poll()
+		select {
+		case <-t.stop:
+			if t.file != nil {
+				t.file.Close()
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// close stops a running tailer and waits for it to finish.
+func (t *tailer) close() {
+	close(t.stop)
+	<-t.done
+}
+
+// poll reads the lines appended since the last poll, following rotation and
+// truncation.
+func (t *tailer) poll() {
+	if t.file == nil {
+		if !t.open() {
+			return
+		}
+	}
+	t.readLines()
+
+	info, err := os.Stat(t.path)
+	if err != nil {
+		// The file was rotated away and its successor is not there yet;
+		// keep reading the old file until it is.
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.logger.Error("Error checking tailed file", "file", t.path, "err", err)
+		}
+		return
+	}
+	current, err := t.file.Stat()
+	if err != nil {
+		t.logger.Error("Error checking tailed file", "file", t.path, "err", err)
+		return
+	}
+
+	switch {
+	case !os.SameFile(info, current):
+		t.file.Close()
+		t.file = nil
+		t.fromStart = true
+		if t.open() {
+			t.readLines()
+		}
+	case info.Size() < t.offset:
+		if _, err := t.file.Seek(0, io.SeekStart); err != nil {
+			t.logger.Error("Error rewinding truncated file", "file", t.path, "err", err)
+			return
+		}
+		t.offset = 0
+		t.partial = ""
+		t.reader.Reset(t.file)
+		t.readLines()
+	}
+}
+
+// open opens the file, reporting whether it succeeded.
+func (t *tailer) open() bool {
+	f, err := os.Open(t.path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.logger.Error("Error opening tailed file", "file", t.path, "err", err)
+		}
+		// A file that appears later is read from its start.
+		t.fromStart = true
+		return false
+	}
+
+	t.offset = 0
+	if !t.fromStart {
+		if t.offset, err = f.Seek(0, io.SeekEnd); err != nil {
+			t.logger.Error("Error seeking to end of tailed file", "file", t.path, "err", err)
+			f.Close()
+			return false
+		}
+	}
+	t.file = f
+	t.partial = ""
+	if t.reader == nil {
+		t.reader = bufio.NewReader(f)
+	} else {
+		t.reader.Reset(f)
+	}
+	return true
+}
+
+// readLines hands every complete line up to the end of the file to handle,
+// keeping a trailing partial line for the next poll.
+func (t *tailer) readLines() {
+	for {
+		chunk, err := t.reader.ReadString('\n')
+		t.offset += int64(len(chunk))
+		t.partial += chunk
+		if err != nil {
+			if err != io.EOF {
+				t.logger.Error("Error reading tailed file", "file", t.path, "err", err)
+			}
+			return
+		}
+		line := strings.TrimRight(t.partial, "\r\n")
+		t.partial = ""
+		t.handle(line)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/common/promslog"
+)
+
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTailer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access_log")
+	appendFile(t, path, "old line\n")
+
+	var lines []string
+	tl := newTailer(promslog.NewNopLogger(), path, func(line string) {
+		lines = append(lines, line)
+	})
+	expect := func(step string, expected ...string) {
+		t.Helper()
+		tl.poll()
+		if !reflect.DeepEqual(lines, expected) {
+			t.Errorf("%s: expected %q, got %q", step, expected, lines)
+		}
+		lines = nil
+	}
+
+	// Lines already in the file when tailing starts are skipped.
+	expect("start")
+
+	appendFile(t, path, "one\ntw")
+	expect("append", "one")
+	appendFile(t, path, "o\r\n")
+	expect("partial line", "two")
+
+	// Rotation: the rest of the old file is read, then the new file from
+	// its start.
+	appendFile(t, path, "three\n")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path+".1", "four\n")
+	expect("rotated away", "three", "four")
+	appendFile(t, path, "five\n")
+	expect("new file", "five")
+
+	// Truncation: the file is read again from its start.
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "six\n")
+	expect("truncated", "six")
+}
+
+func TestTailerMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access_log")
+
+	var lines []string
+	tl := newTailer(promslog.NewNopLogger(), path, func(line string) {
+		lines = append(lines, line)
+	})
+	tl.poll()
+
+	// A file created after tailing started is read from its start.
+	appendFile(t, path, "one\n")
+	tl.poll()
+	if !reflect.DeepEqual(lines, []string{"one"}) {
+		t.Errorf("expected [one], got %q", lines)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package collector
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// tlsSessionCacheStat is a numeric line of the TLSSessionCacheStatus block.
+type tlsSessionCacheStat struct {
+	desc        *prometheus.Desc
+	valueType   prometheus.ValueType
+	labelValues []string
+}
+
+// tlsSessionCacheMetrics exports the TLSSessionCacheStatus block mod_ssl adds
+// to server-status, e.g.
+//
+//	TLSSessionCacheStatus
+//	CacheType: SHMCB
+//	CacheSharedMemory: 512000
+//	CacheIndexUsage: 0%
+//	CacheRetrieveHitCount: 0
+//	...
+type tlsSessionCacheMetrics struct {
+	info           *prometheus.Desc
+	sharedMemory   *prometheus.Desc
+	currentEntries *prometheus.Desc
+	subcaches      *prometheus.Desc
+	indexes        *prometheus.Desc
+	indexUsage     *prometheus.Desc
+	usage          *prometheus.Desc
+	stores         *prometheus.Desc
+	replaces       *prometheus.Desc
+	expires        *prometheus.Desc
+	discards       *prometheus.Desc
+	retrieves      *prometheus.Desc
+	removes        *prometheus.Desc
+
+	// stats maps the numeric lines to their metric.
+	stats map[string]tlsSessionCacheStat
+}
+
+func newTLSSessionCacheMetrics() *tlsSessionCacheMetrics {
+	newDesc := func(name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "tls_session_cache", name), help, labels, nil)
+	}
+	m := &tlsSessionCacheMetrics{
+		info:           newDesc("info", "mod_ssl TLS session cache type", "type"),
+		sharedMemory:   newDesc("shared_memory_bytes", "Size of the TLS session cache shared memory segment"),
+		currentEntries: newDesc("current_entries", "Sessions currently held in the TLS session cache"),
+		subcaches:      newDesc("subcaches", "Number of TLS session cache subcaches"),
+		indexes:        newDesc("indexes_per_subcache", "Number of indexes per TLS session cache subcache"),
+		indexUsage:     newDesc("index_usage_ratio", "Fraction of TLS session cache indexes in use"),
+		usage:          newDesc("usage_ratio", "Fraction of TLS session cache data space in use"),
+		stores:         newDesc("stores_total", "Sessions stored in the TLS session cache"),
+		replaces:       newDesc("replaces_total", "Sessions replaced in the TLS session cache"),
+		expires:        newDesc("expires_total", "Sessions expired from the TLS session cache"),
+		discards:       newDesc("discards_total", "Unexpired sessions discarded from the TLS session cache for lack of space"),
+		retrieves:      newDesc("retrieves_total", "TLS session cache lookups by result (hit, miss)", "result"),
+		removes:        newDesc("removes_total", "TLS session cache removals by result (hit, miss)", "result"),
+	}
+	m.stats = map[string]tlsSessionCacheStat{
+		"CacheSharedMemory":        {m.sharedMemory, prometheus.GaugeValue, nil},
+		"CacheCurrentEntries":      {m.currentEntries, prometheus.GaugeValue, nil},
+		"CacheSubcaches":           {m.subcaches, prometheus.GaugeValue, nil},
+		"CacheIndexesPerSubcaches": {m.indexes, prometheus.GaugeValue, nil},
+		"CacheIndexUsage":          {m.indexUsage, prometheus.GaugeValue, nil},
+		"CacheUsage":               {m.usage, prometheus.GaugeValue, nil},
+		"CacheStoreCount":          {m.stores, prometheus.CounterValue, nil},
+		"CacheReplaceCount":        {m.replaces, prometheus.CounterValue, nil},
+		"CacheExpireCount":         {m.expires, prometheus.CounterValue, nil},
+		"CacheDiscardCount":        {m.discards, prometheus.CounterValue, nil},
+		"CacheRetrieveHitCount":    {m.retrieves, prometheus.CounterValue, []string{"hit"}},
+		"CacheRetrieveMissCount":   {m.retrieves, prometheus.CounterValue, []string{"miss"}},
+		"CacheRemoveHitCount":      {m.removes, prometheus.CounterValue, []string{"hit"}},
+		"CacheRemoveMissCount":     {m.removes, prometheus.CounterValue, []string{"miss"}},
+	}
+	return m
+}
+
+func (m *tlsSessionCacheMetrics) describe(ch chan<- *prometheus.Desc) {
+	ch <- m.info
+	ch <- m.sharedMemory
+	ch <- m.currentEntries
+	ch <- m.subcaches
+	ch <- m.indexes
+	ch <- m.indexUsage
+	ch <- m.usage
+	ch <- m.stores
+	ch <- m.replaces
+	ch <- m.expires
+	ch <- m.discards
+	ch <- m.retrieves
+	ch <- m.removes
+}
+
+// collect exports a Cache* line of server-status, returning whether the key
+// is known. Percentages are exported as ratios.
+func (m *tlsSessionCacheMetrics) collect(ch chan<- prometheus.Metric, key, v string) (bool, error) {
+	if key == "CacheType" {
+		ch <- prometheus.MustNewConstMetric(m.info, prometheus.GaugeValue, 1, v)
+		return true, nil
+	}
+	stat, ok := m.stats[key]
+	if !ok {
+		return false, nil
+	}
+
+	scale := 1.0
+	if strings.HasSuffix(v, "%") {
+		v = strings.TrimSuffix(v, "%")
+		scale = 0.01
+	}
+	val, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return true, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	ch <- prometheus.MustNewConstMetric(stat.desc, stat.valueType, val*scale, stat.labelValues...)
+	return true, nil
+}
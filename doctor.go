@@ -0,0 +1,264 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/Lusitaniae/apache_exporter/collector"
+)
+
+var doctorCmd = kingpin.Command("doctor", "Scrape the status page once and diagnose common mod_status misconfigurations, reporting the metrics they leave out and how to fix them. Exits 1 if a problem was found.")
+
+// doctorMaxRedirects is the number of redirects followed, as by the scrape.
+const doctorMaxRedirects = 10
+
+const (
+	// A scrape that gets an error status still sets apache_up to 1.
+	doctorMissingAll = "all metrics of the status page; apache_up is 1 but apache_exporter_scrape_failures_total increases on every scrape"
+	// A page that is not ?auto output is parsed without errors.
+	doctorMissingSilently = "all metrics of the status page, silently: apache_up is 1, no scrape fails and apache_info has version UNKNOWN"
+)
+
+var reHTMLTitle = regexp.MustCompile(`(?is)<title>\s*(.*?)\s*</title>`)
+
+// Severities of the findings of the doctor command.
+const (
+	doctorOK = iota
+	doctorInfo
+	doctorWarning
+	doctorProblem
+)
+
+var doctorSeverityNames = []string{"OK", "INFO", "WARN", "FAIL"}
+
+// doctorFinding is a result of the doctor command, with the metrics a
+// problem leaves out and how to fix it.
+type doctorFinding struct {
+	severity int
+	summary  string
+	missing  string
+	fix      string
+}
+
+// doctor diagnoses the scrape of a status page.
+type doctor struct {
+	reader   *collector.StatusReader
+	exporter *collector.Exporter
+	findings []doctorFinding
+}
+
+func (d *doctor) add(severity int, summary, missing, fix string) {
+	d.findings = append(d.findings, doctorFinding{severity: severity, summary: summary, missing: missing, fix: fix})
+}
+
+// fetch requests uri, following redirects and reporting them. It returns the
+// final response and URI, or nil if there is none to diagnose.
+func (d *doctor) fetch(uri string) (*collector.StatusResponse, string) {
+	for i := 0; ; i++ {
+		resp, err := d.reader.Fetch(uri)
+		if err != nil {
+			d.add(doctorProblem, fmt.Sprintf("Could not request %s: %s", uri, err),
+				"all metrics of the status page; apache_up is 0",
+				"check that Apache listens on the host and port of --scrape_uri; for https, set --insecure if the certificate does not verify")
+			return nil, ""
+		}
+		if resp.StatusCode < 300 || resp.StatusCode >= 400 {
+			return resp, uri
+		}
+
+		location := resp.Header.Get("Location")
+		current, _ := url.Parse(uri)
+		next, err := current.Parse(location)
+		if location == "" || err != nil {
+			d.add(doctorProblem, fmt.Sprintf("%s answered %s without a valid Location", uri, resp.Status), doctorMissingAll,
+				"point --scrape_uri at the server-status page itself")
+			return nil, ""
+		}
+		if i == doctorMaxRedirects {
+			d.add(doctorProblem, fmt.Sprintf("Stopped after %d redirects at %s", doctorMaxRedirects, uri), doctorMissingAll,
+				"point --scrape_uri at the server-status page itself")
+			return nil, ""
+		}
+		if current.Scheme == "http" && next.Scheme == "https" {
+			d.add(doctorWarning, fmt.Sprintf("%s redirects to HTTPS (%s)", uri, resp.Status),
+				"none while the redirect keeps ?auto, but every scrape makes two requests",
+				fmt.Sprintf("set --scrape_uri=%s, with --insecure if the certificate does not verify for that host", next))
+		} else {
+			d.add(doctorWarning, fmt.Sprintf("%s redirects to %s (%s)", uri, next, resp.Status),
+				"none while the redirect keeps ?auto, but every scrape makes two requests",
+				fmt.Sprintf("set --scrape_uri=%s", next))
+		}
+		uri = next.String()
+	}
+}
+
+// checkStatus reports an error status, returning whether the response is
+// 200 OK.
+func (d *doctor) checkStatus(resp *collector.StatusResponse, uri string) bool {
+	u, _ := url.Parse(uri)
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true
+	case http.StatusUnauthorized:
+		d.add(doctorProblem, fmt.Sprintf("%s requires authentication (%s)", uri, resp.Status), doctorMissingAll,
+			"pass credentials with e.g. --custom_headers=Authorization='Basic <base64 user:password>', or exempt the exporter from authentication for the server-status location")
+	case http.StatusForbidden:
+		d.add(doctorProblem, fmt.Sprintf("%s is forbidden (%s), as with the default `Require local` of mod_status", uri, resp.Status), doctorMissingAll,
+			fmt.Sprintf("allow the address the exporter connects from in the <Location %s> block, e.g. `Require ip 192.0.2.10`, or run the exporter on the Apache host and scrape localhost", u.Path))
+	case http.StatusNotFound:
+		d.add(doctorProblem, fmt.Sprintf("%s was not found (%s)", uri, resp.Status), doctorMissingAll,
+			fmt.Sprintf("load mod_status and add `<Location %s> SetHandler server-status </Location>`, or point --scrape_uri at the location it is configured for", u.Path))
+	default:
+		d.add(doctorProblem, fmt.Sprintf("%s answered %s", uri, resp.Status), doctorMissingAll,
+			"check the Apache error log for why the request failed")
+	}
+	return false
+}
+
+// isHTML reports whether a response is an HTML page.
+func isHTML(resp *collector.StatusResponse) bool {
+	if strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		return true
+	}
+	body := bytes.ToLower(bytes.TrimSpace(resp.Body))
+	return bytes.HasPrefix(body, []byte("<!doctype html")) || bytes.HasPrefix(body, []byte("<html"))
+}
+
+// autoURI returns uri with ?auto, and whether it already had it.
+func autoURI(uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri, false
+	}
+	q := u.Query()
+	if q.Has("auto") {
+		return uri, true
+	}
+	if u.RawQuery != "" {
+		u.RawQuery += "&auto"
+	} else {
+		u.RawQuery = "auto"
+	}
+	return u.String(), false
+}
+
+// checkPage diagnoses the page a 200 OK response served. An HTML status page
+// served for a URI without ?auto is diagnosed again with it.
+func (d *doctor) checkPage(resp *collector.StatusResponse, uri string, retry bool) {
+	if !isHTML(resp) {
+		d.checkAuto(resp, uri)
+		return
+	}
+
+	withAuto, hasAuto := autoURI(uri)
+	if bytes.Contains(resp.Body, []byte("Apache Server Status for")) {
+		if hasAuto {
+			d.add(doctorProblem, fmt.Sprintf("%s serves the HTML status page although ?auto is set", uri), doctorMissingSilently,
+				"make sure no RewriteRule or proxy in front of Apache drops the query string of the server-status location")
+			return
+		}
+		d.add(doctorProblem, fmt.Sprintf("%s serves the HTML status page, the exporter reads the ?auto format", uri), doctorMissingSilently,
+			fmt.Sprintf("set --scrape_uri=%s", withAuto))
+		if retry {
+			if resp, uri := d.fetch(withAuto); resp != nil && d.checkStatus(resp, uri) {
+				d.checkPage(resp, uri, false)
+			}
+		}
+		return
+	}
+
+	page := "an HTML page"
+	if m := reHTMLTitle.FindSubmatch(resp.Body); m != nil {
+		page = fmt.Sprintf("an HTML page titled %q", m[1])
+	}
+	d.add(doctorProblem, fmt.Sprintf("%s serves %s instead of mod_status", uri, page), doctorMissingSilently,
+		"put `SetHandler server-status` in a <Location> matching the path, and keep rewrites such as a CMS front controller in .htaccess from taking it first, e.g. with `RewriteRule ^/?server-status - [L]`")
+}
+
+// checkAuto diagnoses server-status?auto output by parsing it as a scrape
+// would.
+func (d *doctor) checkAuto(resp *collector.StatusResponse, uri string) {
+	dump := &statusDump{exporter: d.exporter, data: resp.Body}
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(dump)
+	mfs, err := reg.Gather()
+	if err != nil {
+		d.add(doctorProblem, fmt.Sprintf("The metrics of %s could not be gathered: %s", uri, err), doctorMissingAll, "")
+		return
+	}
+	g := newGatheredMetrics(mfs)
+
+	if _, ok := g.value("apache_workers"); !ok && len(dump.report.Unparsed) > 0 {
+		d.add(doctorProblem, fmt.Sprintf("%s does not serve server-status?auto output, it starts with %q", uri, dump.report.Unparsed[0]), doctorMissingSilently,
+			"point --scrape_uri at the location with `SetHandler server-status`")
+		return
+	}
+	d.add(doctorOK, fmt.Sprintf("%s serves server-status?auto output", uri), "", "")
+
+	for _, err := range dump.report.Errors {
+		d.add(doctorProblem, fmt.Sprintf("Field error: %s", err), "the metrics of the lines after it, as a scrape stops at the first field error",
+			"report the line with your Apache version as an issue of the exporter")
+	}
+	for _, line := range dump.report.Unparsed {
+		d.add(doctorInfo, fmt.Sprintf("Line not used by the exporter: %s", line), "", "")
+	}
+
+	if _, ok := g.value("apache_accesses_total"); !ok {
+		d.add(doctorWarning, "ExtendedStatus is off: there are no Total Accesses, Total kBytes, Total Duration, CPU or Uptime lines",
+			"apache_accesses_total, apache_sent_kilobytes_total, apache_duration_ms_total, apache_cpu_time_ms_total, apache_cpuload and apache_uptime_seconds_total; "+
+				"the uptime of the check command, the requests per second of top, and the worker table that --collector.status_page, --collector.process without a pidfile and top's longest requests read",
+			"set `ExtendedStatus On` in the server config and restart Apache, as a graceful restart does not change it")
+	} else if _, ok := g.value("apache_cpu_time_ms_total"); !ok {
+		d.add(doctorWarning, "There are no CPU lines, as on platforms where Apache cannot read the CPU time of its processes",
+			"apache_cpu_time_ms_total and apache_cpuload", "")
+	}
+}
+
+// runDoctor diagnoses the scrape of config.ScrapeURI, writing a report to w.
+// It returns the exit code.
+func runDoctor(w io.Writer, logger *slog.Logger, config *collector.Config) int {
+	reader, err := collector.NewStatusReader(config)
+	if err != nil {
+		logger.Error("Invalid scrape URI", "err", err)
+		return 1
+	}
+	d := &doctor{reader: reader, exporter: collector.NewExporter(logger, config)}
+	if resp, uri := d.fetch(config.ScrapeURI); resp != nil && d.checkStatus(resp, uri) {
+		d.checkPage(resp, uri, true)
+	}
+
+	fmt.Fprintf(w, "Diagnosing %s\n\n", config.ScrapeURI)
+	code := 0
+	for _, f := range d.findings {
+		fmt.Fprintf(w, "[%s] %s\n", doctorSeverityNames[f.severity], f.summary)
+		if f.missing != "" {
+			fmt.Fprintf(w, "       Missing: %s\n", f.missing)
+		}
+		if f.fix != "" {
+			fmt.Fprintf(w, "       Fix: %s\n", f.fix)
+		}
+		if f.severity >= doctorWarning {
+			code = 1
+		}
+	}
+	if code == 0 {
+		fmt.Fprintln(w, "\nNo problems found.")
+	}
+	return code
+}
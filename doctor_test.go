@@ -0,0 +1,175 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Lusitaniae/apache_exporter/collector"
+	"github.com/prometheus/common/promslog"
+)
+
+// runTestDoctor runs the doctor command against handler, returning the exit
+// code and report.
+func runTestDoctor(t *testing.T, handler http.Handler, path string) (int, string) {
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	var out strings.Builder
+	code := runDoctor(&out, promslog.NewNopLogger(), &collector.Config{ScrapeURI: server.URL + path})
+	return code, strings.ReplaceAll(out.String(), server.URL, "http://apache")
+}
+
+func serveText(text string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=ISO-8859-1")
+		w.Write([]byte(text))
+	})
+}
+
+func TestDoctorOK(t *testing.T) {
+	code, report := runTestDoctor(t, serveText(apache24EventStatus), "/server-status?auto")
+	if code != 0 {
+		t.Errorf("expected exit code 0, got %d", code)
+	}
+	expected := `Diagnosing http://apache/server-status?auto
+
+[OK] http://apache/server-status?auto serves server-status?auto output
+
+No problems found.
+`
+	if report != expected {
+		t.Errorf("expected report:\n%s\ngot:\n%s", expected, report)
+	}
+}
+
+func TestDoctorMissingAuto(t *testing.T) {
+	page, err := os.ReadFile("collector/testdata/server-status.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, report := runTestDoctor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.RawQuery == "auto" {
+			serveText(apache24EventStatus).ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=ISO-8859-1")
+		w.Write(page)
+	}), "/server-status")
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	for _, want := range []string{
+		"[FAIL] http://apache/server-status serves the HTML status page, the exporter reads the ?auto format\n",
+		"Fix: set --scrape_uri=http://apache/server-status?auto\n",
+		"[OK] http://apache/server-status?auto serves server-status?auto output\n",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("expected %q in report:\n%s", want, report)
+		}
+	}
+}
+
+func TestDoctorExtendedStatusOff(t *testing.T) {
+	var lines []string
+	for _, line := range strings.Split(apache24EventStatus, "\n") {
+		key, _, _ := strings.Cut(line, ":")
+		switch key {
+		case "Total Accesses", "Total kBytes", "Total Duration", "CPUUser", "CPUSystem", "CPUChildrenUser", "CPUChildrenSystem",
+			"CPULoad", "Uptime", "ReqPerSec", "BytesPerSec", "BytesPerReq", "DurationPerReq":
+			continue
+		}
+		lines = append(lines, line)
+	}
+	code, report := runTestDoctor(t, serveText(strings.Join(lines, "\n")), "/server-status?auto")
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	for _, want := range []string{
+		"[WARN] ExtendedStatus is off",
+		"Missing: apache_accesses_total, apache_sent_kilobytes_total",
+		"Fix: set `ExtendedStatus On`",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("expected %q in report:\n%s", want, report)
+		}
+	}
+}
+
+func TestDoctorNoCPU(t *testing.T) {
+	status := strings.Replace(apache24EventStatus, "CPUUser: .25\nCPUSystem: .15\nCPUChildrenUser: 0\nCPUChildrenSystem: 0\nCPULoad: .0898876\n", "", 1)
+	_, report := runTestDoctor(t, serveText(status), "/server-status?auto")
+	if !strings.Contains(report, "[WARN] There are no CPU lines") || strings.Contains(report, "ExtendedStatus is off") {
+		t.Errorf("expected only the CPU lines to be missing, got:\n%s", report)
+	}
+}
+
+func TestDoctorForbidden(t *testing.T) {
+	code, report := runTestDoctor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	}), "/server-status?auto")
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	for _, want := range []string{
+		"[FAIL] http://apache/server-status?auto is forbidden (403 Forbidden), as with the default `Require local` of mod_status\n",
+		"Missing: all metrics of the status page; apache_up is 1",
+		"<Location /server-status> block",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("expected %q in report:\n%s", want, report)
+		}
+	}
+}
+
+func TestDoctorHTTPSRedirect(t *testing.T) {
+	tlsServer := httptest.NewTLSServer(serveText(apache24EventStatus))
+	defer tlsServer.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, tlsServer.URL+r.URL.RequestURI(), http.StatusMovedPermanently)
+	}))
+	defer server.Close()
+
+	var out strings.Builder
+	config := &collector.Config{ScrapeURI: server.URL + "/server-status?auto", Insecure: true}
+	if code := runDoctor(&out, promslog.NewNopLogger(), config); code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	report := out.String()
+	for _, want := range []string{
+		"[WARN] " + server.URL + "/server-status?auto redirects to HTTPS (301 Moved Permanently)\n",
+		"Fix: set --scrape_uri=" + tlsServer.URL + "/server-status?auto, with --insecure",
+		"[OK] " + tlsServer.URL + "/server-status?auto serves server-status?auto output\n",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("expected %q in report:\n%s", want, report)
+		}
+	}
+}
+
+func TestDoctorOtherHTMLPage(t *testing.T) {
+	code, report := runTestDoctor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		w.Write([]byte("<!DOCTYPE html>\n<html><head><title>Page not found - My Blog</title></head><body></body></html>\n"))
+	}), "/server-status?auto")
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	for _, want := range []string{
+		`[FAIL] http://apache/server-status?auto serves an HTML page titled "Page not found - My Blog" instead of mod_status`,
+		"Missing: all metrics of the status page, silently",
+		"RewriteRule ^/?server-status - [L]",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("expected %q in report:\n%s", want, report)
+		}
+	}
+}
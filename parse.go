@@ -0,0 +1,155 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/Lusitaniae/apache_exporter/collector"
+)
+
+var (
+	parseCmd    = kingpin.Command("parse", "Print the metrics of a captured server-status?auto page, reporting the lines that were not used on stderr and exiting 1 if a value could not be parsed.")
+	parseFile   = parseCmd.Arg("file", "Captured server-status?auto page; - for stdin.").Default("-").String()
+	parseFormat = parseCmd.Flag("format", "Output format: text, openmetrics or json.").Default("text").Enum("text", "openmetrics", "json")
+)
+
+// statusDump collects the metrics of a captured status page.
+type statusDump struct {
+	exporter *collector.Exporter
+	data     []byte
+	report   *collector.StatusReport
+}
+
+// Describe implements Prometheus.Collector.
+func (d *statusDump) Describe(ch chan<- *prometheus.Desc) {
+	d.exporter.Describe(ch)
+}
+
+// Collect implements Prometheus.Collector.
+func (d *statusDump) Collect(ch chan<- prometheus.Metric) {
+	d.report = d.exporter.CollectStatus(ch, d.data)
+}
+
+// jsonMetricFamily is a metric family in the JSON output of the parse
+// command. Values are strings, as in the Prometheus HTTP API, to represent
+// NaN and infinities.
+type jsonMetricFamily struct {
+	Name    string       `json:"name"`
+	Help    string       `json:"help"`
+	Type    string       `json:"type"`
+	Metrics []jsonMetric `json:"metrics"`
+}
+
+type jsonMetric struct {
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  string            `json:"value"`
+}
+
+// runParse parses the status page in file, or stdin for "-", with the
+// collector settings of config and writes the metrics to stdout in format.
+// The lines that were not used are reported on stderr. It returns the exit
+// code.
+func runParse(stdin io.Reader, stdout, stderr io.Writer, logger *slog.Logger, config *collector.Config, file, format string) int {
+	var (
+		data []byte
+		err  error
+	)
+	// kingpin parses a "-" argument as an empty one.
+	if file == "-" || file == "" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		logger.Error("Error reading status page", "err", err)
+		return 1
+	}
+
+	dump := &statusDump{exporter: collector.NewExporter(logger, config), data: data}
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(dump)
+	mfs, err := reg.Gather()
+	if err != nil {
+		logger.Error("Error gathering metrics", "err", err)
+		return 1
+	}
+
+	if err := writeMetrics(stdout, mfs, format); err != nil {
+		logger.Error("Error writing metrics", "err", err)
+		return 1
+	}
+
+	for _, line := range dump.report.Unparsed {
+		fmt.Fprintf(stderr, "unparsed line: %s\n", line)
+	}
+	for _, err := range dump.report.Errors {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+	}
+	if len(dump.report.Errors) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// writeMetrics writes metric families in the text, openmetrics or json
+// format.
+func writeMetrics(w io.Writer, mfs []*dto.MetricFamily, format string) error {
+	if format == "json" {
+		families := make([]jsonMetricFamily, 0, len(mfs))
+		for _, mf := range mfs {
+			family := jsonMetricFamily{
+				Name: mf.GetName(),
+				Help: mf.GetHelp(),
+				Type: strings.ToLower(mf.GetType().String()),
+			}
+			for _, m := range mf.GetMetric() {
+				metric := jsonMetric{
+					Value: strconv.FormatFloat(m.GetGauge().GetValue()+m.GetCounter().GetValue()+m.GetUntyped().GetValue(), 'g', -1, 64),
+				}
+				for _, l := range m.GetLabel() {
+					if metric.Labels == nil {
+						metric.Labels = make(map[string]string)
+					}
+					metric.Labels[l.GetName()] = l.GetValue()
+				}
+				family.Metrics = append(family.Metrics, metric)
+			}
+			families = append(families, family)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(families)
+	}
+
+	expFormat := expfmt.NewFormat(expfmt.TypeTextPlain)
+	if format == "openmetrics" {
+		expFormat = expfmt.NewFormat(expfmt.TypeOpenMetrics)
+	}
+	enc := expfmt.NewEncoder(w, expFormat)
+	for _, mf := range mfs {
+		if err := enc.Encode(mf); err != nil {
+			return err
+		}
+	}
+	if closer, ok := enc.(expfmt.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
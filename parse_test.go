@@ -0,0 +1,105 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Lusitaniae/apache_exporter/collector"
+	"github.com/prometheus/common/promslog"
+)
+
+func TestRunParse(t *testing.T) {
+	var stdout, stderr strings.Builder
+	code := runParse(strings.NewReader(apache24EventProxyStatus), &stdout, &stderr, promslog.NewNopLogger(), &collector.Config{}, "-", "text")
+	if code != 0 {
+		t.Errorf("expected exit code 0, got %d", code)
+	}
+	if stderr.Len() > 0 {
+		t.Errorf("expected no report, got %q", stderr.String())
+	}
+	for _, want := range []string{
+		"apache_accesses_total 131\n",
+		"apache_version 2.04023\n",
+		`apache_proxy_balancer_accesses_total{balancer="balancer://myproxy1",worker="https://app-01:9143"} 5808`,
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("expected %q in output", want)
+		}
+	}
+	if strings.Contains(stdout.String(), "apache_up ") {
+		t.Error("expected no apache_up in output")
+	}
+}
+
+func TestRunParseReport(t *testing.T) {
+	status := strings.Replace(apache24EventStatus, "Load1: 0.02\n", "Load1: 0,02\nGracefulWorkers: 3\n", 1)
+
+	var stdout, stderr strings.Builder
+	code := runParse(strings.NewReader(status), &stdout, &stderr, promslog.NewNopLogger(), &collector.Config{}, "-", "openmetrics")
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	expected := `unparsed line: GracefulWorkers: 3
+error: invalid Load1 on line 11: strconv.ParseFloat: parsing "0,02": invalid syntax
+`
+	if stderr.String() != expected {
+		t.Errorf("expected report %q, got %q", expected, stderr.String())
+	}
+	// The lines after the error are still parsed.
+	if !strings.Contains(stdout.String(), "apache_scoreboard{state=\"reply\"} 1.0\n") {
+		t.Error("expected the scoreboard in output")
+	}
+	if !strings.HasSuffix(stdout.String(), "# EOF\n") {
+		t.Error("expected OpenMetrics output to end with # EOF")
+	}
+}
+
+func TestRunParseJSON(t *testing.T) {
+	var stdout, stderr strings.Builder
+	if code := runParse(strings.NewReader(apache22Status), &stdout, &stderr, promslog.NewNopLogger(), &collector.Config{}, "-", "json"); code != 0 {
+		t.Errorf("expected exit code 0, got %d", code)
+	}
+
+	var families []jsonMetricFamily
+	if err := json.Unmarshal([]byte(stdout.String()), &families); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, f := range families {
+		if f.Name != "apache_workers" {
+			continue
+		}
+		found = true
+		if f.Type != "gauge" || len(f.Metrics) != 2 {
+			t.Errorf("unexpected family %+v", f)
+		}
+		for _, m := range f.Metrics {
+			if m.Labels["state"] == "busy" && m.Value != "2" {
+				t.Errorf("expected 2 busy workers, got %s", m.Value)
+			}
+		}
+	}
+	if !found {
+		t.Error("expected apache_workers in output")
+	}
+}
+
+func TestRunParseUnknownCacheKey(t *testing.T) {
+	status := apache24EventTLSStatus + "CacheTimeLeftOldestAvg: 120\n"
+
+	var stdout, stderr strings.Builder
+	if code := runParse(strings.NewReader(status), &stdout, &stderr, promslog.NewNopLogger(), &collector.Config{}, "-", "text"); code != 0 {
+		t.Errorf("expected exit code 0, got %d", code)
+	}
+	if expected := "unparsed line: CacheTimeLeftOldestAvg: 120\n"; stderr.String() != expected {
+		t.Errorf("expected report %q, got %q", expected, stderr.String())
+	}
+}
@@ -0,0 +1,354 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/Lusitaniae/apache_exporter/collector"
+)
+
+var (
+	topCmd        = kingpin.Command("top", "Show a live dashboard of Apache in the terminal: the scoreboard, worker trends, requests per second, proxy balancer members and, with ExtendedStatus On, the longest running requests.")
+	topInterval   = topCmd.Flag("interval", "Time between refreshes.").Short('n').Default("2s").Duration()
+	topRequests   = topCmd.Flag("requests", "Number of longest running requests to show from the HTML status page; 0 to not fetch it.").Default("10").Int()
+	topIterations = topCmd.Flag("iterations", "Number of refreshes before exiting; 0 to run until interrupted.").Default("0").Int()
+)
+
+const (
+	ansiClear   = "\x1b[H\x1b[2J"
+	ansiReset   = "\x1b[0m"
+	ansiBold    = "\x1b[1m"
+	ansiDim     = "\x1b[2m"
+	ansiRed     = "\x1b[31m"
+	ansiGreen   = "\x1b[32m"
+	ansiYellow  = "\x1b[33m"
+	ansiBlue    = "\x1b[34m"
+	ansiMagenta = "\x1b[35m"
+	ansiCyan    = "\x1b[36m"
+)
+
+const (
+	// topHistory is the number of samples the trends show.
+	topHistory = 60
+	// topScoreboardWidth is the number of worker slots per row of the
+	// scoreboard grid.
+	topScoreboardWidth = 64
+	// topRequestWidth truncates the request column.
+	topRequestWidth = 80
+)
+
+// topScoreboardKeys are the scoreboard characters with the names of Apache's
+// scoreboard key, in its order.
+var topScoreboardKeys = []struct {
+	char  byte
+	name  string
+	color string
+}{
+	{'_', "waiting", ansiDim},
+	{'S', "starting", ansiBlue},
+	{'R', "reading", ansiYellow},
+	{'W', "sending", ansiGreen},
+	{'K', "keepalive", ansiCyan},
+	{'D', "dns", ansiMagenta},
+	{'C', "closing", ansiMagenta},
+	{'L', "logging", ansiMagenta},
+	{'G', "finishing", ansiRed},
+	{'I', "idle cleanup", ansiMagenta},
+	{'.', "open", ansiDim},
+}
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// topSample is a reading of the scrape target.
+type topSample struct {
+	time       time.Time
+	err        error
+	metrics    gatheredMetrics
+	scoreboard string
+	workers    []collector.StatusWorker
+	workersErr error
+}
+
+// parseTopSample parses a server-status?auto page read at a time.
+func parseTopSample(exporter *collector.Exporter, data []byte, at time.Time) *topSample {
+	s := &topSample{time: at, scoreboard: collector.StatusScoreboard(data)}
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(&statusDump{exporter: exporter, data: data})
+	mfs, err := reg.Gather()
+	if err != nil {
+		s.err = err
+		return s
+	}
+	s.metrics = newGatheredMetrics(mfs)
+	return s
+}
+
+// readTopSample fetches the status pages of the scrape target.
+func readTopSample(reader *collector.StatusReader, exporter *collector.Exporter, requests int) *topSample {
+	at := time.Now()
+	data, err := reader.Auto()
+	if err != nil {
+		return &topSample{time: at, err: err}
+	}
+	s := parseTopSample(exporter, data, at)
+	if s.err == nil && requests > 0 {
+		s.workers, s.workersErr = reader.Workers()
+	}
+	return s
+}
+
+// topView is the state of the dashboard between refreshes.
+type topView struct {
+	uri      string
+	requests int
+	// prev is the last sample read, for the request rate.
+	prev             *topSample
+	busy, idle, rate []float64
+}
+
+// appendTrend appends v to a trend, dropping the samples that no longer fit.
+func appendTrend(trend []float64, v float64) []float64 {
+	trend = append(trend, v)
+	if len(trend) > topHistory {
+		trend = trend[len(trend)-topHistory:]
+	}
+	return trend
+}
+
+// add records a sample in the trends. The request rate is the increase of
+// the accesses since the previous sample, which is skipped after a restart.
+func (v *topView) add(s *topSample) {
+	if s.err != nil {
+		return
+	}
+	busy, _ := s.metrics.value("apache_workers", "state", "busy")
+	idle, _ := s.metrics.value("apache_workers", "state", "idle")
+	v.busy = appendTrend(v.busy, busy)
+	v.idle = appendTrend(v.idle, idle)
+
+	accesses, ok := s.metrics.value("apache_accesses_total")
+	if ok && v.prev != nil {
+		prevAccesses, prevOK := v.prev.metrics.value("apache_accesses_total")
+		elapsed := s.time.Sub(v.prev.time).Seconds()
+		if prevOK && accesses >= prevAccesses && elapsed > 0 {
+			v.rate = appendTrend(v.rate, (accesses-prevAccesses)/elapsed)
+		}
+	}
+	v.prev = s
+}
+
+// sparkline draws values scaled to max.
+func sparkline(values []float64, max float64) string {
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if max > 0 {
+			i = int(math.Round(v / max * float64(len(sparkBlocks)-1)))
+			i = min(i, len(sparkBlocks)-1)
+		}
+		b.WriteRune(sparkBlocks[i])
+	}
+	return b.String()
+}
+
+// render draws the dashboard for a sample, replacing the screen.
+func (v *topView) render(w io.Writer, s *topSample) {
+	var b bytes.Buffer
+	b.WriteString(ansiClear)
+	fmt.Fprintf(&b, "%sapache_exporter top - %s - %s%s\n", ansiBold, v.uri, s.time.Format(time.TimeOnly), ansiReset)
+	if s.err != nil {
+		fmt.Fprintf(&b, "\n%sError scraping Apache: %s%s\n", ansiRed, s.err, ansiReset)
+		w.Write(b.Bytes())
+		return
+	}
+
+	for _, m := range s.metrics["apache_info"].GetMetric() {
+		fmt.Fprintf(&b, "%s, %s MPM", metricLabel(m, "version"), metricLabel(m, "mpm"))
+	}
+	if uptime, ok := s.metrics.value("apache_uptime_seconds_total"); ok {
+		fmt.Fprintf(&b, ", up %s", time.Duration(uptime)*time.Second)
+	}
+	b.WriteString("\n\n")
+
+	v.renderTrends(&b)
+	v.renderScoreboard(&b, s.scoreboard)
+	v.renderBalancers(&b, s.metrics)
+	v.renderRequests(&b, s)
+	w.Write(b.Bytes())
+}
+
+func (v *topView) renderTrends(b *bytes.Buffer) {
+	var max float64
+	for i := range v.busy {
+		max = math.Max(max, math.Max(v.busy[i], v.idle[i]))
+	}
+	last := func(trend []float64) float64 {
+		if len(trend) == 0 {
+			return 0
+		}
+		return trend[len(trend)-1]
+	}
+	fmt.Fprintf(b, "Busy workers  %s%s%s %g\n", ansiGreen, sparkline(v.busy, max), ansiReset, last(v.busy))
+	fmt.Fprintf(b, "Idle workers  %s%s%s %g\n", ansiDim, sparkline(v.idle, max), ansiReset, last(v.idle))
+	if len(v.rate) == 0 {
+		b.WriteString("Requests/s    (after the next refresh, requires ExtendedStatus On)\n\n")
+		return
+	}
+	var maxRate float64
+	for _, r := range v.rate {
+		maxRate = math.Max(maxRate, r)
+	}
+	fmt.Fprintf(b, "Requests/s    %s%s%s %.2f\n\n", ansiCyan, sparkline(v.rate, maxRate), ansiReset, last(v.rate))
+}
+
+func (v *topView) renderScoreboard(b *bytes.Buffer, scoreboard string) {
+	if scoreboard == "" {
+		return
+	}
+	fmt.Fprintf(b, "%sScoreboard%s (%d slots)\n", ansiBold, ansiReset, len(scoreboard))
+	colors := make(map[byte]string)
+	for _, k := range topScoreboardKeys {
+		colors[k.char] = k.color
+	}
+	// Rows of open slots only are collapsed, as Apache lists every slot
+	// ServerLimit allows.
+	open := 0
+	for i := 0; i < len(scoreboard); i += topScoreboardWidth {
+		row := scoreboard[i:min(i+topScoreboardWidth, len(scoreboard))]
+		if strings.Trim(row, ".") == "" {
+			open += len(row)
+			continue
+		}
+		if open > 0 {
+			fmt.Fprintf(b, "%s... %d open slots%s\n", ansiDim, open, ansiReset)
+			open = 0
+		}
+		for j := 0; j < len(row); j++ {
+			fmt.Fprintf(b, "%s%c%s", colors[row[j]], row[j], ansiReset)
+		}
+		b.WriteString("\n")
+	}
+	if open > 0 {
+		fmt.Fprintf(b, "%s... %d open slots%s\n", ansiDim, open, ansiReset)
+	}
+
+	var key []string
+	for _, k := range topScoreboardKeys {
+		if n := strings.Count(scoreboard, string(k.char)); n > 0 {
+			key = append(key, fmt.Sprintf("%s%c%s %s %d", k.color, k.char, ansiReset, k.name, n))
+		}
+	}
+	fmt.Fprintf(b, "%s\n\n", strings.Join(key, "  "))
+}
+
+func (v *topView) renderBalancers(b *bytes.Buffer, g gatheredMetrics) {
+	members := g["apache_proxy_balancer_status"].GetMetric()
+	if len(members) == 0 {
+		return
+	}
+	sort.Slice(members, func(i, j int) bool {
+		bi, bj := metricLabel(members[i], "balancer"), metricLabel(members[j], "balancer")
+		if bi != bj {
+			return bi < bj
+		}
+		return metricLabel(members[i], "worker") < metricLabel(members[j], "worker")
+	})
+
+	fmt.Fprintf(b, "%sProxy balancers%s\n", ansiBold, ansiReset)
+	// The colored status is the last column, so the escapes do not throw
+	// off the alignment.
+	tw := tabwriter.NewWriter(b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "BALANCER\tWORKER\tBUSY\tSTATUS")
+	for _, m := range members {
+		balancer, worker, status := metricLabel(m, "balancer"), metricLabel(m, "worker"), metricLabel(m, "status")
+		busy, _ := g.value("apache_proxy_balancer_busy", "balancer", balancer, "worker", worker)
+		color := ansiYellow
+		switch {
+		case strings.Contains(status, "Err"):
+			color = ansiRed
+		case strings.Contains(status, "Ok"):
+			color = ansiGreen
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%g\t%s%s%s\n", balancer, worker, busy, color, status, ansiReset)
+	}
+	tw.Flush()
+	b.WriteString("\n")
+}
+
+func (v *topView) renderRequests(b *bytes.Buffer, s *topSample) {
+	if v.requests <= 0 {
+		return
+	}
+	fmt.Fprintf(b, "%sLongest running requests%s\n", ansiBold, ansiReset)
+	if s.workersErr != nil {
+		fmt.Fprintf(b, "%sNot available: %s%s\n", ansiDim, s.workersErr, ansiReset)
+		return
+	}
+
+	var busy []collector.StatusWorker
+	for _, w := range s.workers {
+		if w.Busy {
+			busy = append(busy, w)
+		}
+	}
+	sort.SliceStable(busy, func(i, j int) bool { return busy[i].Seconds > busy[j].Seconds })
+	if len(busy) > v.requests {
+		busy = busy[:v.requests]
+	}
+
+	tw := tabwriter.NewWriter(b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SS\tPID\tM\tCLIENT\tVHOST\tREQUEST")
+	for _, w := range busy {
+		request := w.Request
+		if len(request) > topRequestWidth {
+			request = request[:topRequestWidth-3] + "..."
+		}
+		fmt.Fprintf(tw, "%g\t%s\t%s\t%s\t%s\t%s\n", w.Seconds, w.PID, w.Mode, w.Client, w.VHost, request)
+	}
+	tw.Flush()
+}
+
+// runTop refreshes the dashboard every interval until interrupted, or for
+// the given number of iterations. It returns the exit code.
+func runTop(w io.Writer, logger *slog.Logger, config *collector.Config, interval time.Duration, iterations, requests int) int {
+	if interval <= 0 {
+		logger.Error("The refresh interval must be positive", "interval", interval)
+		return 1
+	}
+	reader, err := collector.NewStatusReader(config)
+	if err != nil {
+		logger.Error("Invalid scrape URI", "err", err)
+		return 1
+	}
+	exporter := collector.NewExporter(logger, config)
+	view := &topView{uri: config.ScrapeURI, requests: requests}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for i := 0; iterations == 0 || i < iterations; i++ {
+		if i > 0 {
+			<-ticker.C
+		}
+		s := readTopSample(reader, exporter, requests)
+		view.add(s)
+		view.render(w, s)
+	}
+	return 0
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2015 neezgee
+//
+// Licensed under the MIT license: https://opensource.org/licenses/MIT
+// Permission is granted to use, copy, modify, and redistribute the work.
+// Full license information available in the project LICENSE file.
+//
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lusitaniae/apache_exporter/collector"
+	"github.com/prometheus/common/promslog"
+)
+
+func TestTopRequestRate(t *testing.T) {
+	exporter := collector.NewExporter(promslog.NewNopLogger(), &collector.Config{})
+	start := time.Date(2016, 7, 29, 14, 6, 15, 0, time.UTC)
+	v := &topView{}
+
+	v.add(parseTopSample(exporter, []byte(apache24EventStatus), start))
+	if len(v.rate) != 0 {
+		t.Errorf("expected no request rate after one sample, got %v", v.rate)
+	}
+	later := strings.Replace(apache24EventStatus, "Total Accesses: 131", "Total Accesses: 151", 1)
+	v.add(parseTopSample(exporter, []byte(later), start.Add(10*time.Second)))
+	if len(v.rate) != 1 || v.rate[0] != 2 {
+		t.Errorf("expected a request rate of 2/s, got %v", v.rate)
+	}
+	// After a restart the accesses go down, which is no rate.
+	v.add(parseTopSample(exporter, []byte(apache24EventStatus), start.Add(20*time.Second)))
+	if len(v.rate) != 1 {
+		t.Errorf("expected no request rate after a restart, got %v", v.rate)
+	}
+	if len(v.busy) != 3 || v.busy[2] != 1 || v.idle[2] != 74 {
+		t.Errorf("unexpected worker trends %v, %v", v.busy, v.idle)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	if got := sparkline([]float64{0, 1, 2, 4}, 4); got != "▁▃▅█" {
+		t.Errorf("expected ▁▃▅█, got %s", got)
+	}
+	if got := sparkline([]float64{0, 0}, 0); got != "▁▁" {
+		t.Errorf("expected ▁▁, got %s", got)
+	}
+}
+
+func TestRunTop(t *testing.T) {
+	page, err := os.ReadFile("collector/testdata/server-status.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.RawQuery == "auto" {
+			w.Write([]byte(apache24EventProxyStatus))
+			return
+		}
+		w.Write(page)
+	}))
+	defer server.Close()
+
+	var out strings.Builder
+	config := &collector.Config{ScrapeURI: server.URL + "/server-status?auto"}
+	if code := runTop(&out, promslog.NewNopLogger(), config, time.Millisecond, 2, 3); code != 0 {
+		t.Errorf("expected exit code 0, got %d", code)
+	}
+
+	frames := strings.Split(out.String(), ansiClear)
+	if len(frames) != 3 {
+		t.Fatalf("expected 2 frames, got %d", len(frames)-1)
+	}
+	frame := frames[2]
+	for _, want := range []string{
+		"Apache/2.4.23 (Unix), event MPM, up 7m25s\n",
+		"Requests/s    ",
+		"(5 slots)",
+		"waiting 4",
+		"balancer://myproxy2  https://app-02:8143  0     " + ansiGreen + "Init Ok",
+		"34  4212  R  192.0.2.21",
+	} {
+		if !strings.Contains(frame, want) {
+			t.Errorf("expected %q in frame:\n%s", want, frame)
+		}
+	}
+	// Only the three longest running of the busy workers are shown.
+	if strings.Contains(frame, "POST /v1/orders") {
+		t.Error("expected at most 3 requests")
+	}
+}
+
+func TestRunTopDown(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	var out strings.Builder
+	config := &collector.Config{ScrapeURI: server.URL + "/server-status?auto"}
+	if code := runTop(&out, promslog.NewNopLogger(), config, time.Millisecond, 1, 10); code != 0 {
+		t.Errorf("expected exit code 0, got %d", code)
+	}
+	if !strings.Contains(out.String(), "Error scraping Apache: status 404") {
+		t.Errorf("expected the scrape error, got %q", out.String())
+	}
+}